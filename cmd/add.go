@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/Shikachuu/helm-vendor-plugin/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+// NewAddCommand creates and returns a cobra command that adds a chart to the vendor-charts configuration file,
+// after checking that the chart exists in its repository.
+func NewAddCommand() *cobra.Command {
+	var (
+		vc       config.VendorChart
+		download bool
+	)
+
+	addCmd := &cobra.Command{
+		Use:   "add <repo-url|@alias> <chart>",
+		Short: "Adds a chart to the vendor-charts configuration file.",
+		Long: "Adds a chart to the vendor-charts configuration file, after checking that it exists in the repository. " +
+			"The repository is either a URL, a local directory or the @alias of a repository added with `helm repo add`. " +
+			"Without --version the latest version is used. The config file is created if it doesn't exist.",
+		Args:        cobra.ExactArgs(2),
+		Annotations: map[string]string{annotationConfigOptional: "true"},
+		RunE: func(_ *cobra.Command, args []string) error {
+			opts, err := fetchOptions()
+			if err != nil {
+				return err
+			}
+
+			vc.Name = args[1]
+
+			vc.Repository, err = helm.ResolveRepository(helmCLI, args[0])
+			if err != nil {
+				return err
+			}
+
+			vc.Version, err = helm.ResolveChartVersion(helmCLI, &vc)
+			if err != nil {
+				return err
+			}
+
+			src, err := os.ReadFile(configPath)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to read config file: %w", err)
+			}
+
+			var edited []byte
+
+			if len(src) == 0 {
+				edited = config.MarshalYAML([]config.VendorChart{vc})
+			} else {
+				edited, err = config.AppendChart(src, &vc)
+				if err != nil {
+					return err
+				}
+			}
+
+			vcs, err := writeConfigEdits(map[string][]byte{configPath: edited})
+			if err != nil {
+				return err
+			}
+
+			slog.Info("chart added", "name", vc.Name, "version", vc.Version, "repo", vc.Repository, "path", configPath)
+
+			if !download {
+				return nil
+			}
+
+			added, err := config.Select(vcs, []string{vc.Name}, nil)
+			if err != nil {
+				return err
+			}
+
+			for _, a := range added {
+				if a.Version == vc.Version {
+					fetched := []config.VendorChart{a}
+
+					results, err := helm.FetchCharts(helmCLI, fetched, opts)
+
+					// The lock marks the destination as vendored for later downloads.
+					return errors.Join(err, writeLock(vcs, fetched, results))
+				}
+			}
+
+			return nil
+		},
+	}
+
+	addCmd.Flags().StringVar(&vc.Version, "version", "", "Chart version or constraint, defaults to the latest version.")
+	addCmd.Flags().StringVar(&vc.Destination, "destination", "artifacts/{{.Name}}", "Destination of the vendored chart.")
+	addCmd.Flags().BoolVar(&vc.Extract, "extract", false, "Extract the chart instead of storing the tgz file.")
+	addCmd.Flags().BoolVar(&vc.Insecure, "insecure", false, "Allow insecure (non-TLS) connections to the repository.")
+	addCmd.Flags().BoolVar(&vc.Verify, "verify", false, "Verify chart provenance.")
+	addCmd.Flags().StringVar(&vc.Ref, "ref", "", "Tag, branch or commit to check out from a git repository.")
+	addCmd.Flags().StringVar(&vc.Path, "path", "", "Path of the chart directory inside a git repository.")
+	addCmd.Flags().StringVar(&vc.Dependencies, "dependencies", "", "Vendor the dependencies of an extracted chart, one of: none, build, update.")
+	addCmd.Flags().BoolVar(&download, "download", false, "Vendor the chart right after adding it.")
+	addFetchFlags(addCmd)
+
+	return addCmd
+}
+
+// writeConfigEdits writes the edited config files, then loads and validates the configuration.
+// Every file is restored to its previous content if the edited configuration is invalid.
+// Returns the loaded charts or an error if any.
+func writeConfigEdits(edits map[string][]byte) ([]config.VendorChart, error) {
+	originals := map[string][]byte{}
+
+	for p, content := range edits {
+		orig, err := os.ReadFile(p)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		originals[p] = orig
+
+		err = os.WriteFile(p, content, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write config file: %w", err)
+		}
+	}
+
+	jcp, err := config.NewJSONConfigParser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate json config parser: %w", err)
+	}
+
+	vcs, err := jcp.Load(configPath)
+	if err == nil {
+		return vcs, nil
+	}
+
+	for p, orig := range originals {
+		if orig == nil {
+			_ = os.Remove(p)
+			continue
+		}
+
+		_ = os.WriteFile(p, orig, 0o600)
+	}
+
+	return nil, fmt.Errorf("edited configuration is invalid, changes were reverted: %w", err)
+}
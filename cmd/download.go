@@ -1,33 +1,33 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 
 	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
 	"github.com/Shikachuu/helm-vendor-plugin/internal/helm"
 	"github.com/spf13/cobra"
-	"sigs.k8s.io/yaml"
 )
 
+// downloadReport is the structured output of the download command.
+type downloadReport struct {
+	Charts   []helm.Result       `json:"charts"`
+	Problems []config.Diagnostic `json:"problems,omitempty"`
+}
+
 // NewDownloadCommand creates and returns a new cobra command for downloading helm charts.
 // It reads the vendor charts configuration file, parses it, and downloads each specified
 // helm chart to its designated destination directory.
 func NewDownloadCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "download",
+	downloadCmd := &cobra.Command{
+		Use:   "download [chart...]",
 		Short: "Download, downloads the helm charts defined in the config file.",
-		Long:  "Download, downloads the helm charts defined in the config file to their given locations.",
-		RunE: func(_ *cobra.Command, _ []string) error {
-			cfg, err := os.ReadFile(configPath)
-			if err != nil {
-				return fmt.Errorf("failed to read config file: %w", err)
-			}
-
-			cfg, err = yaml.YAMLToJSON(cfg)
+		Long:  "Download, downloads the helm charts defined in the config file to their given locations, optionally only the charts with the given names or tags.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := fetchOptions()
 			if err != nil {
-				return fmt.Errorf("failed to convert yaml configuration to json: %w", err)
+				return err
 			}
 
 			jcp, err := config.NewJSONConfigParser()
@@ -35,12 +35,43 @@ func NewDownloadCommand() *cobra.Command {
 				return fmt.Errorf("failed to initiate json config parser: %w", err)
 			}
 
-			vcs, err := jcp.Unmarshall(cfg)
+			all, err := jcp.Load(configPath)
+
+			var vcs []config.VendorChart
+			if err == nil {
+				vcs, err = selectCharts(all, args)
+			}
+
 			if err != nil {
+				if machineOutput() {
+					wErr := writeOutput(cmd.OutOrStdout(), downloadReport{Charts: []helm.Result{}, Problems: problemsFromError(err)})
+					if wErr != nil {
+						return wErr
+					}
+				}
+
 				return err
 			}
 
-			err = helm.FetchCharts(helmCLI, vcs)
+			results, err := helm.FetchCharts(helmCLI, vcs, opts)
+
+			if machineOutput() {
+				if results == nil {
+					results = []helm.Result{}
+				}
+
+				wErr := writeOutput(cmd.OutOrStdout(), downloadReport{Charts: results})
+				if wErr != nil {
+					return wErr
+				}
+			}
+
+			// Charts that were vendored are locked even if others failed.
+			lErr := writeLock(all, vcs, results)
+			if lErr != nil {
+				return errors.Join(err, lErr)
+			}
+
 			if err != nil {
 				return err
 			}
@@ -50,4 +81,51 @@ func NewDownloadCommand() *cobra.Command {
 			return nil
 		},
 	}
+
+	addOutputFlag(downloadCmd)
+	addSelectionFlags(downloadCmd)
+	addFetchFlags(downloadCmd)
+
+	return downloadCmd
+}
+
+// writeLock records the successfully vendored charts in the lock file of the configuration file
+// and drops the charts that are not configured anymore. Results are in the order of vcs, all is every configured chart.
+func writeLock(all, vcs []config.VendorChart, results []helm.Result) error {
+	lockPath := config.LockPath(configPath)
+
+	l, err := config.ReadLock(lockPath)
+	if err != nil {
+		return err
+	}
+
+	locked := make([]config.LockedChart, 0, len(results))
+
+	for i, r := range results {
+		if r.Error != "" || r.Destination == "" {
+			continue
+		}
+
+		locked = append(locked, config.LockedChart{
+			Name:           r.Name,
+			Version:        r.Version,
+			Repository:     vcs[i].Repository,
+			URL:            r.URL,
+			Commit:         r.Commit,
+			Digest:         r.Digest,
+			RepackedDigest: r.RepackedDigest,
+			Destination:    r.Destination,
+			Dependencies:   r.Dependencies,
+			Files:          r.Files,
+		})
+	}
+
+	// Don't create a lock file when there is nothing to record.
+	if len(locked) == 0 && len(l.Charts) == 0 {
+		return nil
+	}
+
+	l.Merge(locked, all)
+
+	return l.Write(lockPath)
 }
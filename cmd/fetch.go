@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/Shikachuu/helm-vendor-plugin/internal/helm"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var (
+	maxChartSize  string
+	maxFileSize   string
+	maxChartFiles int
+	force         bool
+	savePatches   bool
+)
+
+// addFetchFlags adds the flags controlling how charts are vendored to the command.
+func addFetchFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&maxChartSize, "max-chart-size", resource.NewQuantity(helm.DefaultExtractLimits.TotalSize, resource.BinarySI).String(),
+		"Maximum uncompressed size of a chart archive, like 100Mi, 0 disables the limit.")
+	cmd.Flags().StringVar(&maxFileSize, "max-file-size", resource.NewQuantity(helm.DefaultExtractLimits.FileSize, resource.BinarySI).String(),
+		"Maximum uncompressed size of a single file in a chart archive, like 5Mi, 0 disables the limit.")
+	cmd.Flags().IntVar(&maxChartFiles, "max-chart-files", helm.DefaultExtractLimits.Entries,
+		"Maximum number of entries in a chart archive, 0 disables the limit.")
+	cmd.Flags().BoolVar(&force, "force", false,
+		"Overwrite destinations with content that was not vendored, which are neither in the lock file nor have a marker file, "+
+			"and discard the local modifications of extracted charts.")
+	cmd.Flags().BoolVar(&savePatches, "save-patches", false,
+		"Save the local modifications of extracted charts as patches in their patches directory before overwriting them.")
+}
+
+// fetchOptions builds the options of helm.FetchCharts from the fetch flags and the lock file of the configuration.
+func fetchOptions() (helm.FetchOptions, error) {
+	total, err := resource.ParseQuantity(maxChartSize)
+	if err != nil {
+		return helm.FetchOptions{}, fmt.Errorf("invalid --max-chart-size: %w", err)
+	}
+
+	file, err := resource.ParseQuantity(maxFileSize)
+	if err != nil {
+		return helm.FetchOptions{}, fmt.Errorf("invalid --max-file-size: %w", err)
+	}
+
+	l, err := config.ReadLock(config.LockPath(configPath))
+	if err != nil {
+		return helm.FetchOptions{}, err
+	}
+
+	return helm.FetchOptions{
+		Limits:      helm.ExtractLimits{TotalSize: total.Value(), FileSize: file.Value(), Entries: maxChartFiles},
+		Version:     version,
+		Lock:        l,
+		Force:       force,
+		SavePatches: savePatches,
+	}, nil
+}
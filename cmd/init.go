@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/Shikachuu/helm-vendor-plugin/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var errConfigExists = errors.New("config file already exists, use --force to overwrite it")
+
+// NewInitCommand creates and returns a cobra command that scaffolds a vendor-charts configuration file
+// from the charts already vendored in a directory tree.
+func NewInitCommand() *cobra.Command {
+	var force bool
+
+	initCmd := &cobra.Command{
+		Use:   "init [directory]",
+		Short: "Generates a vendor-charts configuration file from already vendored charts.",
+		Long: "Generates a vendor-charts configuration file by scanning the directory (default: the working directory) " +
+			"for chart archives and extracted charts. Repositories are guessed from the cached indexes of the configured " +
+			"Helm repositories, and left as TODO when no repository serves the chart.",
+		Args:        cobra.MaximumNArgs(1),
+		Annotations: map[string]string{annotationConfigOptional: "true"},
+		RunE: func(_ *cobra.Command, args []string) error {
+			root := "."
+			if len(args) > 0 {
+				root = args[0]
+			}
+
+			if _, err := os.Stat(configPath); err == nil && !force {
+				return fmt.Errorf("%w: %s", errConfigExists, configPath)
+			}
+
+			vcs, err := helm.ScanCharts(helmCLI, root)
+			if err != nil {
+				return err
+			}
+
+			err = os.WriteFile(configPath, config.MarshalYAML(vcs), 0o600)
+			if err != nil {
+				return fmt.Errorf("failed to write config file: %w", err)
+			}
+
+			missing := 0
+
+			for _, vc := range vcs {
+				if vc.Repository == "" {
+					missing++
+				}
+			}
+
+			slog.Info("config file generated", "path", configPath, "charts", len(vcs), "missing_repositories", missing)
+
+			return nil
+		},
+	}
+
+	initCmd.Flags().BoolVar(&force, "force", false, "Overwrite the config file if it already exists.")
+
+	return initCmd
+}
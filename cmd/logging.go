@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+// Supported values of the --log-format flag.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+var (
+	logFormat     string
+	logLevel      string
+	logQuiet      bool
+	logTimestamps bool
+
+	errUnknownLogFormat = errors.New("unknown log format")
+)
+
+// addLoggingFlags registers the logging related persistent flags on the given command.
+func addLoggingFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormatText, "Log format, one of: text, json.")
+	cmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "Log level, one of: debug, info, warn, error. Defaults to debug when HELM_DEBUG is set, info otherwise.")
+	cmd.PersistentFlags().BoolVarP(&logQuiet, "quiet", "q", false, "Only log errors, shorthand for --log-level=error.")
+	cmd.PersistentFlags().BoolVar(&logTimestamps, "log-timestamps", false, "Include timestamps in log records.")
+}
+
+// newLogger creates the logger described by the logging flags, writing to w.
+// The debug argument is the level used when neither --log-level nor --quiet is set.
+func newLogger(w io.Writer, debug bool) (*slog.Logger, error) {
+	ll := slog.LevelInfo
+	if debug {
+		ll = slog.LevelDebug
+	}
+
+	if logLevel != "" {
+		err := ll.UnmarshalText([]byte(logLevel))
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level: %w", err)
+		}
+	}
+
+	if logQuiet {
+		ll = slog.LevelError
+	}
+
+	opts := &slog.HandlerOptions{
+		Level: ll,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if !logTimestamps && len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	}
+
+	if !slices.Contains([]string{logFormatText, logFormatJSON}, logFormat) {
+		return nil, fmt.Errorf("%w: %s", errUnknownLogFormat, logFormat)
+	}
+
+	if logFormat == logFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts)), nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// Supported values of the --output flag.
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+var (
+	outputFormat string
+
+	errUnknownOutputFormat = errors.New("unknown output format")
+)
+
+// addOutputFlag registers the --output flag on the given command.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", outputText, "Output format, one of: text, json, yaml. Structured output is written to stdout, logs to stderr.")
+}
+
+// machineOutput reports whether a structured document was requested instead of log lines.
+func machineOutput() bool {
+	return outputFormat != outputText
+}
+
+// validateOutputFormat checks the value of the --output flag.
+func validateOutputFormat() error {
+	if !slices.Contains([]string{outputText, outputJSON, outputYAML}, outputFormat) {
+		return fmt.Errorf("%w: %s", errUnknownOutputFormat, outputFormat)
+	}
+
+	return nil
+}
+
+// writeOutput encodes v as a single json or yaml document into w.
+func writeOutput(w io.Writer, v any) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode output: %w", err)
+	}
+
+	if outputFormat == outputYAML {
+		out, err = yaml.JSONToYAML(out)
+		if err != nil {
+			return fmt.Errorf("unable to encode output: %w", err)
+		}
+	} else {
+		out = append(out, '\n')
+	}
+
+	_, err = w.Write(out)
+	if err != nil {
+		return fmt.Errorf("unable to write output: %w", err)
+	}
+
+	return nil
+}
+
+// problemsFromError converts a configuration loading error into a list of typed problems.
+// Errors that are not Diagnostics become a single problem of kind error.
+func problemsFromError(err error) []config.Diagnostic {
+	if err == nil {
+		return []config.Diagnostic{}
+	}
+
+	var ds config.Diagnostics
+	if errors.As(err, &ds) {
+		return ds
+	}
+
+	return []config.Diagnostic{{Kind: config.KindError, File: configPath, Message: err.Error()}}
+}
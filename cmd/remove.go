@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/Shikachuu/helm-vendor-plugin/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+// NewRemoveCommand creates and returns a cobra command that removes a chart from the vendor-charts configuration
+// file, including the file that declares it when it was included, and deletes its vendored files.
+func NewRemoveCommand() *cobra.Command {
+	var keepFiles bool
+
+	removeCmd := &cobra.Command{
+		Use:               "remove <chart>",
+		Short:             "Removes a chart from the vendor-charts configuration file.",
+		Long:              "Removes every entry of the chart from the vendor-charts configuration files and deletes its vendored files.",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeChartNames,
+		RunE: func(_ *cobra.Command, args []string) error {
+			jcp, err := config.NewJSONConfigParser()
+			if err != nil {
+				return fmt.Errorf("failed to initiate json config parser: %w", err)
+			}
+
+			vcs, err := jcp.Load(configPath)
+			if err != nil {
+				return err
+			}
+
+			removed, err := config.Select(vcs, args, nil)
+			if err != nil {
+				return err
+			}
+
+			// The lock records what was vendored, read it before the removed charts are dropped from it.
+			l, err := config.ReadLock(config.LockPath(configPath))
+			if err != nil {
+				return err
+			}
+
+			indexes := map[string][]int{}
+			for _, vc := range removed {
+				indexes[vc.Source] = append(indexes[vc.Source], vc.Index)
+			}
+
+			edits := map[string][]byte{}
+
+			for p, idx := range indexes {
+				src, err := os.ReadFile(p)
+				if err != nil {
+					return fmt.Errorf("failed to read config file: %w", err)
+				}
+
+				edits[p], err = config.RemoveCharts(src, idx)
+				if err != nil {
+					return fmt.Errorf("%s: %w", p, err)
+				}
+			}
+
+			// Files are deleted before the configuration is edited, so a refused deletion can be retried with --force.
+			for i := 0; i < len(removed) && !keepFiles; i++ {
+				err = helm.RemoveVendored(&removed[i], l, force)
+				if err != nil {
+					return err
+				}
+			}
+
+			vcs, err = writeConfigEdits(edits)
+			if err != nil {
+				return err
+			}
+
+			err = writeLock(vcs, nil, nil)
+			if err != nil {
+				return err
+			}
+
+			for i := range removed {
+				slog.Info("chart removed", "name", removed[i].Name, "version", removed[i].Version, "path", removed[i].Source)
+			}
+
+			return nil
+		},
+	}
+
+	removeCmd.Flags().BoolVar(&keepFiles, "keep-files", false, "Keep the vendored files of the chart.")
+	removeCmd.Flags().BoolVar(&force, "force", false,
+		"Delete destinations that were not vendored, or whose vendored files are not recorded in the lock file, entirely.")
+
+	return removeCmd
+}
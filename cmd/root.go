@@ -10,6 +10,9 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// annotationConfigOptional marks commands that can run without an existing config file.
+const annotationConfigOptional = "vendor.config-optional"
+
 var (
 	configPath string
 	helmCLI    *helm.Settings
@@ -23,41 +26,41 @@ func NewRootCommand() *cobra.Command {
 		Short:   "Vendor downloads helm charts from remote repositories.",
 		Long:    "Vendor downloads helm charts from OCI and Helm repositories for vendoring, either in unpacked or tgz form.",
 		Version: version,
-		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
-			if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if err := validateOutputFormat(); err != nil {
+				return err
+			}
+
+			if _, err := os.Stat(configPath); os.IsNotExist(err) && cmd.Annotations[annotationConfigOptional] == "" {
 				return fmt.Errorf("config file not found: %s", configPath)
-			} else if err != nil {
+			} else if err != nil && !os.IsNotExist(err) {
 				return fmt.Errorf("error accessing config file: %w", err)
 			}
 
 			helmCLI = helm.NewSettings()
 
-			ll := slog.LevelInfo
-			if helmCLI.Debug {
-				ll = slog.LevelDebug
+			// Logs always go to stderr, so stdout can carry machine readable output.
+			logger, err := newLogger(os.Stderr, helmCLI.Debug)
+			if err != nil {
+				return err
 			}
 
-			slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-				Level: ll,
-				ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
-					if a.Key == slog.TimeKey {
-						return slog.Attr{}
-					}
-
-					return a
-				},
-			})))
+			slog.SetDefault(logger)
 
 			return nil
 		},
 	}
 
 	rootCmd.PersistentFlags().StringVarP(&configPath, "file", "f", ".vendor-charts.yaml", "The file contains the vendor-charts config.")
+	addLoggingFlags(rootCmd)
 
 	rootCmd.AddCommand(
 		NewVerifyCommand(),
 		NewVersionCommand(),
 		NewDownloadCommand(),
+		NewInitCommand(),
+		NewAddCommand(),
+		NewRemoveCommand(),
 	)
 
 	return rootCmd
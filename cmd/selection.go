@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var selectTags []string
+
+// addSelectionFlags makes the given command accept chart names as arguments and the --tag flag,
+// completing the arguments with the chart names of the configuration file.
+func addSelectionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVarP(&selectTags, "tag", "t", nil, "Only process the charts with the given tag, can be repeated.")
+	cmd.Args = cobra.ArbitraryArgs
+	cmd.ValidArgsFunction = completeChartNames
+}
+
+// selectCharts narrows the loaded charts down to the ones named in args or tagged with --tag.
+func selectCharts(vcs []config.VendorChart, args []string) ([]config.VendorChart, error) {
+	return config.Select(vcs, args, selectTags)
+}
+
+// completeChartNames suggests the chart names declared in the configuration file.
+func completeChartNames(_ *cobra.Command, _ []string, _ string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	jcp, err := config.NewJSONConfigParser()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	vcs, err := jcp.Load(configPath)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	names := make([]cobra.Completion, 0, len(vcs))
+	for _, vc := range vcs {
+		names = append(names, vc.Name)
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -3,47 +3,60 @@ package cmd
 import (
 	"fmt"
 	"log/slog"
-	"os"
 
 	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
 	"github.com/spf13/cobra"
-	"sigs.k8s.io/yaml"
 )
 
+// verifyReport is the structured output of the verify command.
+type verifyReport struct {
+	Problems []config.Diagnostic `json:"problems"`
+	Charts   int                 `json:"charts"`
+	Valid    bool                `json:"valid"`
+}
+
 // NewVerifyCommand creates and returns a cobra command that verifies the vendor-charts
-// configuration file by reading it, converting it to JSON, and validating it against
-// the expected schema.
+// configuration file and every file it includes by reading them, converting them to JSON,
+// and validating them against the expected schema.
 func NewVerifyCommand() *cobra.Command {
 	verifyCmd := &cobra.Command{
-		Use:   "verify",
+		Use:   "verify [chart...]",
 		Short: "Verifies the given vendor-charts configuration file.",
-		Long:  "Verifies the given vendor-charts configuration file.",
-		RunE: func(_ *cobra.Command, _ []string) error {
-			cfg, err := os.ReadFile(configPath)
+		Long:  "Verifies the given vendor-charts configuration file, optionally only the charts with the given names or tags.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jcp, err := config.NewJSONConfigParser()
 			if err != nil {
-				return fmt.Errorf("failed to read config file: %w", err)
+				return fmt.Errorf("failed to initiate json config parser: %w", err)
 			}
 
-			cfg, err = yaml.YAMLToJSON(cfg)
-			if err != nil {
-				return fmt.Errorf("failed to convert yaml configuration to json: %w", err)
+			vcs, err := jcp.Load(configPath)
+			if err == nil {
+				vcs, err = selectCharts(vcs, args)
 			}
 
-			jcp, err := config.NewJSONConfigParser()
-			if err != nil {
-				return fmt.Errorf("failed to initiate json config parser: %w", err)
+			if machineOutput() {
+				wErr := writeOutput(cmd.OutOrStdout(), verifyReport{
+					Valid:    err == nil,
+					Charts:   len(vcs),
+					Problems: problemsFromError(err),
+				})
+				if wErr != nil {
+					return wErr
+				}
 			}
 
-			err = jcp.Validate(cfg)
 			if err != nil {
 				return err
 			}
 
-			slog.Info("config is valid", "path", configPath)
+			slog.Info("config is valid", "path", configPath, "charts", len(vcs))
 
 			return nil
 		},
 	}
 
+	addOutputFlag(verifyCmd)
+	addSelectionFlags(verifyCmd)
+
 	return verifyCmd
 }
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kaptinlin/jsonschema"
+	"go.yaml.in/yaml/v3"
+)
+
+// Kinds of Diagnostic, describing which check found the problem.
+const (
+	KindSchema    = "schema"
+	KindTemplate  = "template"
+	KindDuplicate = "duplicate"
+	KindConflict  = "destination-conflict"
+	KindNested    = "nested-destination"
+	KindEscape    = "destination-escape"
+	// KindError is used for problems that are not tied to a field, like unreadable files or include cycles.
+	KindError = "error"
+)
+
+// Diagnostic is a single problem found in a configuration file.
+// Path is the JSON pointer of the offending value, File, Line and Column point to it in the original source when known.
+type Diagnostic struct {
+	Kind    string `json:"kind"`
+	File    string `json:"file,omitempty"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// String renders the diagnostic like a compiler does: `file:line:column: field: message`.
+func (d Diagnostic) String() string {
+	var b strings.Builder
+
+	if d.File != "" {
+		b.WriteString(d.File + ":")
+	}
+
+	if d.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d:", d.Line, d.Column)
+	}
+
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+
+	return b.String() + d.field() + ": " + d.Message
+}
+
+// field renders the JSON pointer as a readable field path, like `charts[0].version`.
+func (d Diagnostic) field() string {
+	if d.Path == "" || d.Path == "/" {
+		return "(root)"
+	}
+
+	var b strings.Builder
+
+	for _, seg := range strings.Split(strings.TrimPrefix(d.Path, "/"), "/") {
+		if _, err := strconv.Atoi(seg); err == nil {
+			b.WriteString("[" + seg + "]")
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteString(".")
+		}
+
+		b.WriteString(unescapePointer(seg))
+	}
+
+	return b.String()
+}
+
+// Diagnostics is a list of configuration problems, it is returned as an error by the parser.
+type Diagnostics []Diagnostic
+
+// Error renders every diagnostic on its own line.
+func (ds Diagnostics) Error() string {
+	var b strings.Builder
+
+	b.WriteString("invalid configuration file:")
+
+	for _, d := range ds {
+		b.WriteString("\n" + d.String())
+	}
+
+	return b.String()
+}
+
+// locate fills in the source position of every diagnostic from the original yaml or json source of its file,
+// then sorts them. Diagnostics pointing to values that can't be found keep the position of their closest parent.
+func (ds Diagnostics) locate(sources map[string][]byte) {
+	roots := map[string]*yaml.Node{}
+
+	for i := range ds {
+		src, ok := sources[ds[i].File]
+		if !ok {
+			continue
+		}
+
+		root, ok := roots[ds[i].File]
+		if !ok {
+			root = &yaml.Node{}
+			roots[ds[i].File] = root
+
+			// Positions are best effort, the source was already parsed successfully when diagnostics are produced.
+			_ = yaml.Unmarshal(src, root)
+		}
+
+		if n := findNode(root, ds[i].Path); n != nil {
+			ds[i].Line, ds[i].Column = n.Line, n.Column
+		}
+	}
+
+	ds.sort()
+}
+
+// sort orders the diagnostics by file, position, path and message, so the output is deterministic.
+func (ds Diagnostics) sort() {
+	slices.SortFunc(ds, func(a, b Diagnostic) int {
+		return cmp.Or(
+			cmp.Compare(a.File, b.File),
+			cmp.Compare(a.Line, b.Line),
+			cmp.Compare(a.Column, b.Column),
+			cmp.Compare(a.Path, b.Path),
+			cmp.Compare(a.Message, b.Message),
+		)
+	})
+}
+
+// findNode walks the yaml node tree along the given JSON pointer.
+// Returns the deepest node found on the way, or nil if the document is empty.
+func findNode(root *yaml.Node, pointer string) *yaml.Node {
+	n := root
+	if n.Kind == yaml.DocumentNode {
+		if len(n.Content) == 0 {
+			return nil
+		}
+
+		n = n.Content[0]
+	}
+
+	if pointer == "" {
+		return n
+	}
+
+	for _, seg := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		next := childNode(n, unescapePointer(seg))
+		if next == nil {
+			return n
+		}
+
+		n = next
+	}
+
+	return n
+}
+
+// childNode returns the value of a mapping key or the item of a sequence index, nil if there is none.
+func childNode(n *yaml.Node, seg string) *yaml.Node {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == seg {
+				return n.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		idx, err := strconv.Atoi(seg)
+		if err == nil && idx >= 0 && idx < len(n.Content) {
+			return n.Content[idx]
+		}
+	case yaml.DocumentNode, yaml.ScalarNode, yaml.AliasNode:
+	}
+
+	return nil
+}
+
+// unescapePointer decodes a single JSON pointer segment.
+func unescapePointer(seg string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(seg, "~1", "/"), "~0", "~")
+}
+
+// aggregateKeywords only summarize the errors of their sub schemas, they are reported through their details.
+var aggregateKeywords = []string{
+	"properties", "items", "prefixItems", "anyOf", "oneOf", "allOf", "then", "else", "additionalProperties",
+}
+
+// schemaDiagnostics collects the leaf errors of a failed JSON schema evaluation of the given json document.
+func schemaDiagnostics(r *jsonschema.EvaluationResult, cfg []byte) Diagnostics {
+	var (
+		ds  Diagnostics
+		doc any
+	)
+
+	// The document was already validated, so it is valid json.
+	_ = json.Unmarshal(cfg, &doc)
+
+	collectDiagnostics(r, doc, "", &ds)
+	ds.sort()
+
+	return ds
+}
+
+// collectDiagnostics walks the evaluation result tree, accumulating the relative instance locations.
+func collectDiagnostics(r *jsonschema.EvaluationResult, doc any, base string, ds *Diagnostics) {
+	loc := base + r.InstanceLocation
+
+	// Missing required properties are also evaluated as null, they are already reported by `required`.
+	if !pointerExists(doc, loc) {
+		return
+	}
+
+	// The details of an additional property only say that the schema is `false`,
+	// report it as a disallowed property at its own location instead.
+	if strings.Contains(r.EvaluationPath, "/additionalProperties/") {
+		*ds = append(*ds, Diagnostic{Kind: KindSchema, Path: loc, Message: "Additional property is not allowed"})
+
+		return
+	}
+
+	for kw, err := range r.Errors {
+		if slices.Contains(aggregateKeywords, kw) {
+			continue
+		}
+
+		*ds = append(*ds, Diagnostic{Kind: KindSchema, Path: loc, Message: err.Error()})
+	}
+
+	for _, d := range r.Details {
+		if d.IsValid() {
+			continue
+		}
+
+		// Failed branches of a satisfied anyOf or oneOf are not problems.
+		if (strings.HasPrefix(d.EvaluationPath, "/anyOf/") && r.Errors["anyOf"] == nil) ||
+			(strings.HasPrefix(d.EvaluationPath, "/oneOf/") && r.Errors["oneOf"] == nil) {
+			continue
+		}
+
+		collectDiagnostics(d, doc, loc, ds)
+	}
+}
+
+// pointerExists reports whether the JSON pointer resolves to a value in the decoded json document.
+func pointerExists(doc any, pointer string) bool {
+	if pointer == "" {
+		return true
+	}
+
+	v := doc
+
+	for _, seg := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		switch val := v.(type) {
+		case map[string]any:
+			next, ok := val[unescapePointer(seg)]
+			if !ok {
+				return false
+			}
+
+			v = next
+		case []any:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(val) {
+				return false
+			}
+
+			v = val[idx]
+		default:
+			return false
+		}
+	}
+
+	return true
+}
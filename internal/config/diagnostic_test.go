@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestDiagnostics_Locate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "yaml source",
+			src: `charts:
+  - name: traefik
+    repository: example.com/charts
+    version: ""
+    destination: vendor/traefik
+    bogus: true
+  - name: cert-manager
+    version: v1.19.1
+    destination: vendor/cert-manager
+`,
+			want: []string{
+				".vendor-charts.yaml:3:17: charts[0].repository: Value does not match the required pattern ^([a-z][a-z0-9+.-]*://|\\.{0,2}/).*",
+				".vendor-charts.yaml:4:14: charts[0].version: Value should be at least 1 characters",
+				".vendor-charts.yaml:6:12: charts[0].bogus: Additional property is not allowed",
+				".vendor-charts.yaml:7:5: charts[1]: Required property 'repository' is missing",
+			},
+		},
+		{
+			name: "json source",
+			src: `{
+  "charts": [
+    {"name": "traefik", "repository": "oci://ghcr.io/traefik/helm", "version": "", "destination": "vendor"}
+  ]
+}`,
+			want: []string{
+				".vendor-charts.yaml:3:80: charts[0].version: Value should be at least 1 characters",
+			},
+		},
+		{
+			name: "git fields on a helm repository",
+			src: `charts:
+  - name: traefik
+    repository: https://traefik.github.io/charts
+    version: 37.0.0
+    destination: vendor
+    ref: main
+  - name: cert-manager
+    repository: git+https://github.com/cert-manager/cert-manager.git
+    version: v1.19.1
+    destination: vendor/cert-manager
+    ref: v1.19.1
+    path: deploy/charts/cert-manager
+`,
+			want: []string{
+				".vendor-charts.yaml:6:10: charts[0].ref: No values are allowed because the schema is set to 'false'",
+			},
+		},
+		{
+			name: "dependencies of a packaged chart",
+			src: `charts:
+  - name: umbrella
+    repository: https://example.com/charts
+    version: 1.0.0
+    destination: vendor
+    dependencies: build
+    extract: false
+`,
+			want: []string{
+				".vendor-charts.yaml:7:14: charts[0].extract: Value does not match the constant value",
+			},
+		},
+		{
+			name: "exclude rules on a packaged chart",
+			src: `charts:
+  - name: ingress-nginx
+    repository: https://kubernetes.github.io/ingress-nginx
+    version: 4.13.3
+    destination: vendor
+    exclude:
+      - ci/
+`,
+			want: []string{
+				".vendor-charts.yaml:2:5: charts[0]: Required property 'extract' is missing",
+			},
+		},
+		{
+			name: "sub-path with the top-level directory",
+			src: `charts:
+  - name: cert-manager
+    repository: oci://quay.io/jetstack/charts
+    version: v1.19.1
+    destination: vendor
+    extract: true
+    subPath: crds
+    keepTopLevel: true
+`,
+			want: []string{
+				".vendor-charts.yaml:8:19: charts[0].keepTopLevel: Value does not match the constant value",
+			},
+		},
+		{
+			name: "sub-path with dependencies",
+			src: `charts:
+  - name: cert-manager
+    repository: oci://quay.io/jetstack/charts
+    version: v1.19.1
+    destination: vendor
+    extract: true
+    subPath: crds
+    dependencies: build
+`,
+			want: []string{
+				".vendor-charts.yaml:8:19: charts[0].dependencies: Value does not match the constant value",
+			},
+		},
+		{
+			name: "missing charts and include",
+			src:  "$schema: schema.json\n",
+			want: []string{
+				".vendor-charts.yaml:1:1: (root): Required property 'charts' is missing",
+				".vendor-charts.yaml:1:1: (root): Required property 'include' is missing",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg, err := yaml.YAMLToJSON([]byte(tt.src))
+			require.NoError(t, err)
+
+			err = newTestParser(t).validateSchema(cfg)
+			require.Error(t, err)
+
+			var ds Diagnostics
+			require.True(t, errors.As(err, &ds))
+
+			for i := range ds {
+				ds[i].File = ".vendor-charts.yaml"
+			}
+
+			ds.locate(map[string][]byte{".vendor-charts.yaml": []byte(tt.src)})
+
+			got := make([]string, 0, len(ds))
+			for _, d := range ds {
+				got = append(got, d.String())
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+var (
+	errUnsupportedLayout = errors.New("only block style yaml chart lists can be edited, please edit the file by hand")
+	errNoChartsLeft      = errors.New("removing every chart would leave an empty configuration, delete the file instead")
+)
+
+// chartsBlock describes where the charts list is located in a yaml source, all line numbers are 0 based.
+type chartsBlock struct {
+	lines []string
+	// items holds the first line of every chart entry.
+	items []int
+	// key is the line of the `charts:` key, -1 if the file has none.
+	key int
+	// end is the line after the last non blank, non comment line of the list.
+	end int
+	// indent is the indentation of the `-` of the entries.
+	indent string
+	// include reports whether the file has an include key, which keeps it valid without charts.
+	include bool
+}
+
+// findChartsBlock locates the block style charts list in a yaml source.
+func findChartsBlock(src []byte) (*chartsBlock, error) {
+	var root yaml.Node
+
+	err := yaml.Unmarshal(src, &root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse configuration: %w", err)
+	}
+
+	cb := &chartsBlock{lines: strings.Split(string(src), "\n"), key: -1, indent: "  "}
+
+	// Drop the empty element after the final newline, it is added back when joining.
+	if cb.lines[len(cb.lines)-1] == "" {
+		cb.lines = cb.lines[:len(cb.lines)-1]
+	}
+
+	cb.end = len(cb.lines)
+
+	if len(root.Content) == 0 {
+		return cb, nil
+	}
+
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode || doc.Style&yaml.FlowStyle != 0 {
+		return nil, errUnsupportedLayout
+	}
+
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == "include" {
+			cb.include = true
+		}
+
+		if doc.Content[i].Value != "charts" || cb.key >= 0 {
+			continue
+		}
+
+		keyNode, seq := doc.Content[i], doc.Content[i+1]
+		cb.key = keyNode.Line - 1
+
+		if seq.Kind != yaml.SequenceNode || seq.Style&yaml.FlowStyle != 0 {
+			return nil, errUnsupportedLayout
+		}
+
+		for _, item := range seq.Content {
+			cb.items = append(cb.items, item.Line-1)
+		}
+
+		if len(seq.Content) > 0 {
+			cb.indent = strings.Repeat(" ", max(seq.Content[0].Column-3, 0))
+		}
+
+		cb.end = cb.blockEnd(keyNode.Column - 1)
+	}
+
+	return cb, nil
+}
+
+// blockEnd returns the line after the last content line belonging to the charts key indented at keyIndent.
+func (cb *chartsBlock) blockEnd(keyIndent int) int {
+	end := cb.key + 1
+
+	for i := cb.key + 1; i < len(cb.lines); i++ {
+		if isBlankOrComment(cb.lines[i]) {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(cb.lines[i])
+
+		indent := len(cb.lines[i]) - len(strings.TrimLeft(cb.lines[i], " "))
+		if indent <= keyIndent && !strings.HasPrefix(trimmed, "-") {
+			break
+		}
+
+		end = i + 1
+	}
+
+	return end
+}
+
+// isBlankOrComment reports whether the yaml line holds no content.
+func isBlankOrComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+// joinLines renders the lines back into a yaml source.
+func joinLines(lines []string) []byte {
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// AppendChart adds the chart as the last entry of the charts list in the yaml source,
+// keeping the rest of the file untouched. A charts list is created at the end of the file if there is none.
+// Returns the edited source or an error if the file layout is not supported.
+func AppendChart(src []byte, vc *VendorChart) ([]byte, error) {
+	cb, err := findChartsBlock(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+
+	writeChartYAML(&b, vc, cb.indent)
+	entry := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+
+	if cb.key < 0 {
+		return joinLines(append(append(cb.lines, "charts:"), entry...)), nil
+	}
+
+	// Keep the blank line separation style of the existing entries.
+	if len(cb.items) > 1 && strings.TrimSpace(cb.lines[cb.items[1]-1]) == "" {
+		entry = append([]string{""}, entry...)
+	}
+
+	lines := slices.Concat(cb.lines[:cb.end], entry, cb.lines[cb.end:])
+
+	return joinLines(lines), nil
+}
+
+// RemoveCharts deletes the entries at the given indexes of the charts list in the yaml source,
+// keeping the rest of the file untouched. The charts key is removed too when no entry is left, which is refused
+// when the file has no include key, since a configuration needs either of them.
+// Returns the edited source or an error if the file layout is not supported.
+func RemoveCharts(src []byte, indexes []int) ([]byte, error) {
+	cb, err := findChartsBlock(src)
+	if err != nil {
+		return nil, err
+	}
+
+	remove := make([]bool, len(cb.lines))
+
+	for _, idx := range indexes {
+		if idx < 0 || idx >= len(cb.items) {
+			return nil, fmt.Errorf("%w: chart index %d out of range", errUnsupportedLayout, idx)
+		}
+
+		to := cb.end
+		if idx+1 < len(cb.items) {
+			to = cb.items[idx+1]
+		}
+
+		// Comments before the next entry belong to it, only the blank lines right after the entry go with it.
+		end := to
+		for end > cb.items[idx] && isBlankOrComment(cb.lines[end-1]) {
+			end--
+		}
+
+		for end < to && strings.TrimSpace(cb.lines[end]) == "" {
+			end++
+		}
+
+		for l := cb.items[idx]; l < end; l++ {
+			remove[l] = true
+		}
+	}
+
+	if len(indexes) == len(cb.items) && cb.key >= 0 {
+		if !cb.include {
+			return nil, errNoChartsLeft
+		}
+
+		for l := cb.key; l < cb.end; l++ {
+			remove[l] = true
+		}
+	}
+
+	lines := make([]string, 0, len(cb.lines))
+
+	for i, l := range cb.lines {
+		if !remove[i] {
+			lines = append(lines, l)
+		}
+	}
+
+	return joinLines(lines), nil
+}
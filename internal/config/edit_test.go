@@ -0,0 +1,239 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendChart(t *testing.T) {
+	t.Parallel()
+
+	vc := VendorChart{Name: "redis", Repository: "oci://registry.example.com/charts", Version: "1.0.0", Destination: "vendor"}
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "appends after the last entry keeping comments",
+			src: `# my charts
+charts:
+  # ingress
+  - name: traefik
+    repository: https://traefik.github.io/charts
+    version: 37.0.0
+    destination: vendor # inline
+
+  - name: cert-manager
+    repository: https://charts.jetstack.io
+    version: v1.19.1
+    destination: vendor
+# trailing comment
+include:
+  - teams/*.yaml
+`,
+			want: `# my charts
+charts:
+  # ingress
+  - name: traefik
+    repository: https://traefik.github.io/charts
+    version: 37.0.0
+    destination: vendor # inline
+
+  - name: cert-manager
+    repository: https://charts.jetstack.io
+    version: v1.19.1
+    destination: vendor
+
+  - name: "redis"
+    repository: "oci://registry.example.com/charts"
+    version: "1.0.0"
+    destination: "vendor"
+# trailing comment
+include:
+  - teams/*.yaml
+`,
+		},
+		{
+			name: "keeps unindented lists",
+			src: `charts:
+- name: traefik
+  repository: https://traefik.github.io/charts
+  version: 37.0.0
+  destination: vendor
+`,
+			want: `charts:
+- name: traefik
+  repository: https://traefik.github.io/charts
+  version: 37.0.0
+  destination: vendor
+- name: "redis"
+  repository: "oci://registry.example.com/charts"
+  version: "1.0.0"
+  destination: "vendor"
+`,
+		},
+		{
+			name: "creates the charts list",
+			src:  "include:\n  - teams/*.yaml\n",
+			want: `include:
+  - teams/*.yaml
+charts:
+  - name: "redis"
+    repository: "oci://registry.example.com/charts"
+    version: "1.0.0"
+    destination: "vendor"
+`,
+		},
+		{
+			name:    "json is not supported",
+			src:     `{"charts": []}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := AppendChart([]byte(tt.src), &vc)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errUnsupportedLayout)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestRemoveCharts(t *testing.T) {
+	t.Parallel()
+
+	src := `# my charts
+charts:
+  # ingress
+  - name: traefik
+    repository: https://traefik.github.io/charts
+    version: 37.0.0
+    destination: vendor
+
+  - name: cert-manager
+    repository: https://charts.jetstack.io
+    version: v1.19.1
+    destination: vendor
+include:
+  - teams/*.yaml
+`
+
+	tests := []struct {
+		name    string
+		indexes []int
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "removes the last entry",
+			indexes: []int{1},
+			want: `# my charts
+charts:
+  # ingress
+  - name: traefik
+    repository: https://traefik.github.io/charts
+    version: 37.0.0
+    destination: vendor
+
+include:
+  - teams/*.yaml
+`,
+		},
+		{
+			name:    "removes the first entry",
+			indexes: []int{0},
+			want: `# my charts
+charts:
+  # ingress
+  - name: cert-manager
+    repository: https://charts.jetstack.io
+    version: v1.19.1
+    destination: vendor
+include:
+  - teams/*.yaml
+`,
+		},
+		{
+			name:    "removes the charts key with the last entries",
+			indexes: []int{0, 1},
+			want: `# my charts
+include:
+  - teams/*.yaml
+`,
+		},
+		{
+			name:    "out of range",
+			indexes: []int{2},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := RemoveCharts([]byte(src), tt.indexes)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errUnsupportedLayout)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestRemoveCharts_Comments(t *testing.T) {
+	t.Parallel()
+
+	src := `charts:
+  - name: a
+    repository: https://example.com
+    version: 1.0.0
+    destination: a
+
+  # comment between
+  - name: b
+    repository: https://example.com
+    version: 1.0.0
+    destination: b
+`
+
+	got, err := RemoveCharts([]byte(src), []int{0})
+	require.NoError(t, err)
+	require.Equal(t, `charts:
+  # comment between
+  - name: b
+    repository: https://example.com
+    version: 1.0.0
+    destination: b
+`, string(got))
+}
+
+func TestRemoveCharts_LastChart(t *testing.T) {
+	t.Parallel()
+
+	src := `charts:
+  - name: traefik
+    repository: https://traefik.github.io/charts
+    version: 37.0.0
+    destination: vendor
+`
+
+	_, err := RemoveCharts([]byte(src), []int{0})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "delete the file instead")
+}
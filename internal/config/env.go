@@ -0,0 +1,95 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// envPattern matches `$$` escapes, `${VAR}` and `${VAR:-default}` references.
+var envPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+var errUndefinedVariables = errors.New("undefined environment variables")
+
+// expandEnv replaces environment variable references in every string value of the given json document.
+// Object keys are left untouched. `$$` can be used to write a literal `$`.
+// Returns the expanded document or an error listing every undefined variable.
+func expandEnv(cfg []byte, lookup func(string) (string, bool)) ([]byte, error) {
+	var doc any
+
+	d := json.NewDecoder(bytes.NewReader(cfg))
+	d.UseNumber()
+
+	err := d.Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse configuration: %w", err)
+	}
+
+	var undefined []string
+
+	doc = expandValue(doc, lookup, &undefined)
+
+	if len(undefined) > 0 {
+		slices.Sort(undefined)
+
+		return nil, fmt.Errorf("%w: %s", errUndefinedVariables, strings.Join(slices.Compact(undefined), ", "))
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode expanded configuration: %w", err)
+	}
+
+	return out, nil
+}
+
+// expandValue walks the decoded json value and expands the variables in every string it finds.
+func expandValue(v any, lookup func(string) (string, bool), undefined *[]string) any {
+	switch val := v.(type) {
+	case string:
+		return expandString(val, lookup, undefined)
+	case []any:
+		for i := range val {
+			val[i] = expandValue(val[i], lookup, undefined)
+		}
+
+		return val
+	case map[string]any:
+		for k := range val {
+			val[k] = expandValue(val[k], lookup, undefined)
+		}
+
+		return val
+	default:
+		return v
+	}
+}
+
+// expandString expands the variable references of a single string.
+// Undefined variables without a default are appended to undefined.
+func expandString(s string, lookup func(string) (string, bool), undefined *[]string) string {
+	return envPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if m == "$$" {
+			return "$"
+		}
+
+		sm := envPattern.FindStringSubmatch(m)
+		name, hasDefault := sm[1], strings.Contains(m, ":-")
+
+		if val, ok := lookup(name); ok && (val != "" || !hasDefault) {
+			return val
+		}
+
+		if hasDefault {
+			return sm[2]
+		}
+
+		*undefined = append(*undefined, name)
+
+		return ""
+	})
+}
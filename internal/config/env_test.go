@@ -0,0 +1,90 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		"CHART_MIRROR": "https://mirror.example.com",
+		"EMPTY":        "",
+	}
+
+	lookup := func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+
+	tests := []struct {
+		name    string
+		cfg     string
+		want    string
+		errMsg  string
+		wantErr bool
+	}{
+		{
+			name:    "defined variable",
+			cfg:     `{"charts":[{"repository":"${CHART_MIRROR}/charts"}]}`,
+			want:    `{"charts":[{"repository":"https://mirror.example.com/charts"}]}`,
+			wantErr: false,
+		},
+		{
+			name:    "default for unset variable",
+			cfg:     `{"version":"${INGRESS_VERSION:-4.11.0}"}`,
+			want:    `{"version":"4.11.0"}`,
+			wantErr: false,
+		},
+		{
+			name:    "default for empty variable",
+			cfg:     `{"version":"${EMPTY:-4.11.0}"}`,
+			want:    `{"version":"4.11.0"}`,
+			wantErr: false,
+		},
+		{
+			name:    "empty variable without default",
+			cfg:     `{"version":"v${EMPTY}"}`,
+			want:    `{"version":"v"}`,
+			wantErr: false,
+		},
+		{
+			name:    "escaped dollar and untouched keys",
+			cfg:     `{"${CHART_MIRROR}":"$${CHART_MIRROR}","n":1}`,
+			want:    `{"${CHART_MIRROR}":"${CHART_MIRROR}","n":1}`,
+			wantErr: false,
+		},
+		{
+			name:    "undefined variables are listed",
+			cfg:     `{"a":"${B_VAR}","b":["${A_VAR}","${B_VAR}"]}`,
+			wantErr: true,
+			errMsg:  "undefined environment variables: A_VAR, B_VAR",
+		},
+		{
+			name:    "invalid json",
+			cfg:     `{`,
+			wantErr: true,
+			errMsg:  "unable to parse configuration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := expandEnv([]byte(tt.cfg), lookup)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
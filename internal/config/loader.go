@@ -0,0 +1,206 @@
+package config
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	errIncludeCycle    = errors.New("include cycle detected")
+	errIncludeNotFound = errors.New("included file not found")
+)
+
+// document is the top level structure of a single vendor-charts configuration file.
+type document struct {
+	Include []string      `json:"include"`
+	Charts  []VendorChart `json:"charts"`
+}
+
+// Load reads the vendor-charts configuration file at the given path and every file it includes recursively.
+// Destinations and local repositories of included files are resolved relative to the directory of the file
+// declaring them, while those of the root file are kept as-is.
+// Every error is prefixed with the file it originates from.
+// The loaded charts are checked together with ValidateSemantics, using the working directory as root.
+// Returns the charts of all files in include order or an error if any.
+func (j *JSONConfigParser) Load(path string) ([]VendorChart, error) {
+	l := newLoader(j)
+
+	vcs, err := l.load(path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine working directory: %w", err)
+	}
+
+	err = ValidateSemantics(vcs, wd)
+
+	var ds Diagnostics
+	if errors.As(err, &ds) {
+		ds.locate(l.sources)
+
+		return nil, ds
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return vcs, nil
+}
+
+// loader holds the state of a single recursive Load call.
+type loader struct {
+	parser  *JSONConfigParser
+	visited map[string]bool
+	sources map[string][]byte
+	stack   []string
+}
+
+// newLoader creates a loader with empty state.
+func newLoader(j *JSONConfigParser) *loader {
+	return &loader{parser: j, visited: map[string]bool{}, sources: map[string][]byte{}}
+}
+
+// load parses a single file then descends into its includes, detecting cycles on the way.
+func (l *loader) load(path string, root bool) ([]VendorChart, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to resolve path: %w", path, err)
+	}
+
+	if slices.Contains(l.stack, abs) {
+		return nil, fmt.Errorf("%w: %s -> %s", errIncludeCycle, strings.Join(l.stack, " -> "), abs)
+	}
+
+	// A file included from several places (but not in a cycle) is only loaded once.
+	if l.visited[abs] {
+		return nil, nil
+	}
+
+	l.visited[abs] = true
+	l.stack = append(l.stack, abs)
+
+	defer func() {
+		l.stack = l.stack[:len(l.stack)-1]
+	}()
+
+	doc, err := l.parseFile(path)
+	if err != nil {
+		var ds Diagnostics
+		if errors.As(err, &ds) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+
+	for i := range doc.Charts {
+		doc.Charts[i].Source = path
+		doc.Charts[i].Index = i
+
+		if root {
+			continue
+		}
+
+		if !filepath.IsAbs(doc.Charts[i].Destination) {
+			doc.Charts[i].Destination = filepath.Join(dir, doc.Charts[i].Destination)
+		}
+
+		// Patches of included files are kept next to them, like their destinations.
+		if doc.Charts[i].Extract {
+			patches := cmp.Or(doc.Charts[i].Patches, DefaultPatches)
+			if !filepath.IsAbs(patches) {
+				doc.Charts[i].Patches = filepath.Join(dir, patches)
+			}
+		}
+
+		if lp, ok := doc.Charts[i].LocalPath(); ok && !filepath.IsAbs(lp) {
+			doc.Charts[i].Repository = localRepositoryPrefix + filepath.Join(dir, lp)
+		}
+	}
+
+	vcs := doc.Charts
+
+	for _, pattern := range doc.Include {
+		files, err := resolveInclude(dir, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		for _, f := range files {
+			ivcs, err := l.load(f, false)
+			if err != nil {
+				return nil, err
+			}
+
+			vcs = append(vcs, ivcs...)
+		}
+	}
+
+	return vcs, nil
+}
+
+// parseFile reads, converts and unmarshalls a single configuration file.
+// Diagnostics are mapped back to their position in the original file.
+func (l *loader) parseFile(path string) (*document, error) {
+	src, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	l.sources[path] = src
+
+	cfg, err := yaml.YAMLToJSON(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert yaml configuration to json: %w", err)
+	}
+
+	doc, err := l.parser.unmarshallDocument(cfg)
+
+	var ds Diagnostics
+	if errors.As(err, &ds) {
+		for i := range ds {
+			ds[i].File = path
+		}
+
+		ds.locate(l.sources)
+
+		return nil, ds
+	}
+
+	return doc, err
+}
+
+// resolveInclude expands a single include entry relative to dir.
+// Glob patterns may match nothing, plain paths must exist.
+func resolveInclude(dir, pattern string) ([]string, error) {
+	p := pattern
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+
+	files, err := filepath.Glob(p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+	}
+
+	if len(files) == 0 && !strings.ContainsAny(pattern, "*?[") {
+		return nil, fmt.Errorf("%w: %s", errIncludeNotFound, pattern)
+	}
+
+	slices.Sort(files)
+
+	return files, nil
+}
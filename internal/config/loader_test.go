@@ -0,0 +1,187 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kaptinlin/jsonschema"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestParser compiles the schema from the package folder, so tests don't depend on the `jsonSchema` global.
+func newTestParser(t *testing.T) *JSONConfigParser {
+	t.Helper()
+
+	s, err := jsonschema.NewCompiler().Compile(readTestFile(t, "schema.json"))
+	require.NoError(t, err)
+
+	return &JSONConfigParser{schema: s}
+}
+
+// writeTestFiles writes the given files relative to dir, creating parent folders as needed.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o750))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+	}
+}
+
+func TestJSONConfigParser_Load(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		files   map[string]string
+		name    string
+		want    map[string]string
+		errMsg  string
+		wantErr bool
+	}{
+		{
+			name: "include with glob and relative destinations",
+			files: map[string]string{
+				"root.yaml":               "include: [teams/*/vendor.yaml]\ncharts:\n  - {name: a, repository: https://example.com, version: 1.0.0, destination: vendor/a}\n",
+				"teams/x/vendor.yaml":     "charts:\n  - {name: x, repository: https://example.com, version: 1.0.0, destination: charts}\n",
+				"teams/y/vendor.yaml":     "include: [../../shared.yaml]\ncharts:\n  - {name: yy, repository: https://example.com, version: 1.0.0, destination: charts}\n",
+				"shared.yaml":             "charts:\n  - {name: s, repository: https://example.com, version: 1.0.0, destination: shared}\n",
+				"teams/x/not-a-vendor.md": "ignored",
+			},
+			want: map[string]string{
+				"a":  "vendor/a",
+				"x":  "teams/x/charts",
+				"yy": "teams/y/charts",
+				"s":  "shared",
+			},
+			wantErr: false,
+		},
+		{
+			name: "include only file",
+			files: map[string]string{
+				"root.yaml": "include: [team.yaml, team.yaml]\n",
+				"team.yaml": "charts:\n  - {name: t, repository: https://example.com, version: 1.0.0, destination: t}\n",
+			},
+			want:    map[string]string{"t": "t"},
+			wantErr: false,
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"root.yaml": "include: [a.yaml]\n",
+				"a.yaml":    "include: [b.yaml]\n",
+				"b.yaml":    "include: [a.yaml]\n",
+			},
+			wantErr: true,
+			errMsg:  "include cycle detected",
+		},
+		{
+			name: "missing include",
+			files: map[string]string{
+				"root.yaml": "include: [missing.yaml]\n",
+			},
+			wantErr: true,
+			errMsg:  "included file not found: missing.yaml",
+		},
+		{
+			name: "validation error names the included file",
+			files: map[string]string{
+				"root.yaml":     "include: [team/bad.yaml]\n",
+				"team/bad.yaml": "charts:\n  - {name: b, version: 1.0.0, destination: b}\n",
+			},
+			wantErr: true,
+			errMsg:  filepath.Join("team", "bad.yaml") + ":2:5: charts[0]: Required property 'repository' is missing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			writeTestFiles(t, dir, tt.files)
+
+			vcs, err := newLoader(newTestParser(t)).load(filepath.Join(dir, "root.yaml"), true)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			got := map[string]string{}
+
+			for _, vc := range vcs {
+				d := vc.Destination
+				if vc.Source != filepath.Join(dir, "root.yaml") {
+					d, err = filepath.Rel(dir, d)
+					require.NoError(t, err)
+				}
+
+				got[vc.Name] = filepath.ToSlash(d)
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestJSONConfigParser_Load_LocalRepositories(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"root.yaml": "include: [teams/x/vendor.yaml]\n" +
+			"charts:\n  - {name: a, repository: ./charts, version: 1.0.0, destination: vendor/a}\n",
+		"teams/x/vendor.yaml": "charts:\n" +
+			"  - {name: x, repository: file://../../charts, version: 1.0.0, destination: charts}\n" +
+			"  - {name: z, repository: /srv/charts, version: 1.0.0, destination: charts}\n",
+	})
+
+	vcs, err := newLoader(newTestParser(t)).load(filepath.Join(dir, "root.yaml"), true)
+	require.NoError(t, err)
+
+	got := map[string]string{}
+	for _, vc := range vcs {
+		got[vc.Name] = vc.Repository
+	}
+
+	require.Equal(t, map[string]string{
+		"a": "./charts",
+		"x": "file://" + filepath.Join(dir, "charts"),
+		"z": "/srv/charts",
+	}, got)
+}
+
+func TestJSONConfigParser_Load_Patches(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"root.yaml": "include: [teams/x/vendor.yaml]\n" +
+			"charts:\n  - {name: a, repository: https://example.com, version: 1.0.0, destination: vendor/a, extract: true}\n",
+		"teams/x/vendor.yaml": "charts:\n" +
+			"  - {name: x, repository: https://example.com, version: 1.0.0, destination: charts/x, extract: true}\n" +
+			"  - {name: w, repository: https://example.com, version: 1.0.0, destination: charts/w, extract: true, patches: fixes}\n" +
+			"  - {name: z, repository: https://example.com, version: 1.0.0, destination: charts/z, extract: true, patches: /srv/fixes}\n",
+	})
+
+	vcs, err := newLoader(newTestParser(t)).load(filepath.Join(dir, "root.yaml"), true)
+	require.NoError(t, err)
+
+	got := map[string]string{}
+	for _, vc := range vcs {
+		got[vc.Name] = vc.PatchPath("1.0.0")
+	}
+
+	require.Equal(t, map[string]string{
+		"a": filepath.Join("patches", "a-1.0.0.patch"),
+		"x": filepath.Join(dir, "teams", "x", "patches", "x-1.0.0.patch"),
+		"w": filepath.Join(dir, "teams", "x", "fixes", "w-1.0.0.patch"),
+		"z": filepath.Join("/srv", "fixes", "z-1.0.0.patch"),
+	}, got)
+}
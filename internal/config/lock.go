@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// lockHeader is written at the top of every lock file.
+const lockHeader = "# Generated by helm vendor download, do not edit.\n"
+
+// Lock records the outcome of the last successful download of every chart.
+type Lock struct {
+	Charts []LockedChart `json:"charts"`
+}
+
+// LockedChart is the vendored state of a single chart.
+type LockedChart struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository"`
+	URL        string `json:"url,omitempty"`
+	Commit     string `json:"commit,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	// RepackedDigest is the digest of the archive written to the destination, when it was repacked.
+	RepackedDigest string             `json:"repackedDigest,omitempty"`
+	Destination    string             `json:"destination"`
+	Dependencies   []LockedDependency `json:"dependencies,omitempty"`
+	// Files holds the digest of every file of an extracted chart by its destination relative path,
+	// to detect local modifications before the chart is vendored again.
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// LockedDependency is a dependency vendored into the `charts/` directory of an extracted chart.
+type LockedDependency struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+}
+
+// LockPath returns the path of the lock file belonging to a configuration file,
+// the configuration path with its extension replaced by `.lock`.
+func LockPath(configPath string) string {
+	return strings.TrimSuffix(configPath, filepath.Ext(configPath)) + ".lock"
+}
+
+// ReadLock reads the lock file at path, a missing file is an empty lock.
+// Returns the lock or an error if any.
+func ReadLock(path string) (*Lock, error) {
+	src, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Lock{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	var l Lock
+
+	err = yaml.Unmarshal(src, &l)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", path, err)
+	}
+
+	return &l, nil
+}
+
+// Write writes the lock to path as yaml.
+func (l *Lock) Write(path string) error {
+	out, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to render lock file: %w", err)
+	}
+
+	err = os.WriteFile(path, append([]byte(lockHeader), out...), 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	return nil
+}
+
+// Merge records the given charts in the lock, replacing the entries with the same destination,
+// then drops the entries of charts that are no longer configured in vcs.
+// Entries are kept sorted by destination so the file is stable.
+func (l *Lock) Merge(charts []LockedChart, vcs []VendorChart) {
+	for _, c := range charts {
+		i := slices.IndexFunc(l.Charts, func(e LockedChart) bool { return e.Destination == c.Destination })
+		if i < 0 {
+			l.Charts = append(l.Charts, c)
+			continue
+		}
+
+		l.Charts[i] = c
+	}
+
+	l.Charts = slices.DeleteFunc(l.Charts, func(e LockedChart) bool {
+		return !slices.ContainsFunc(vcs, func(vc VendorChart) bool { return vc.Name == e.Name })
+	})
+
+	slices.SortFunc(l.Charts, func(a, b LockedChart) int {
+		return strings.Compare(a.Destination, b.Destination)
+	})
+}
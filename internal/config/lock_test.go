@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		configPath string
+		want       string
+	}{
+		{configPath: ".vendor-charts.yaml", want: ".vendor-charts.lock"},
+		{configPath: "deploy/vendor.json", want: "deploy/vendor.lock"},
+		{configPath: "vendor-charts", want: "vendor-charts.lock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.configPath, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.want, LockPath(tt.configPath))
+		})
+	}
+}
+
+func TestLock_ReadWrite(t *testing.T) {
+	t.Parallel()
+
+	p := filepath.Join(t.TempDir(), ".vendor-charts.lock")
+
+	l, err := ReadLock(p)
+	require.NoError(t, err)
+	require.Empty(t, l.Charts)
+
+	l.Charts = []LockedChart{{
+		Name:        "umbrella",
+		Version:     "1.0.0",
+		Repository:  "https://example.com/charts",
+		URL:         "https://example.com/charts/umbrella-1.0.0.tgz",
+		Digest:      "sha256:abc",
+		Destination: "vendor/umbrella",
+		Files: map[string]string{
+			"Chart.yaml":            "sha256:c1",
+			"templates/deploy.yaml": "sha256:d2",
+		},
+		Dependencies: []LockedDependency{
+			{Name: "common", Version: "0.3.0", Repository: "https://example.com/charts", Digest: "sha256:def"},
+		},
+	}, {
+		Name:           "redis",
+		Version:        "20.0.0",
+		Repository:     "oci://registry-1.docker.io/bitnamicharts",
+		Digest:         "sha256:123",
+		RepackedDigest: "sha256:456",
+		Destination:    "vendor/redis/redis-20.0.0.tgz",
+	}}
+
+	require.NoError(t, l.Write(p))
+
+	src, err := os.ReadFile(p)
+	require.NoError(t, err)
+	require.Contains(t, string(src), lockHeader)
+
+	got, err := ReadLock(p)
+	require.NoError(t, err)
+	require.Equal(t, l, got)
+}
+
+func TestLock_Merge(t *testing.T) {
+	t.Parallel()
+
+	l := &Lock{Charts: []LockedChart{
+		{Name: "traefik", Version: "37.0.0", Destination: "vendor/traefik"},
+		{Name: "removed", Version: "1.0.0", Destination: "vendor/removed"},
+		{Name: "cert-manager", Version: "v1.18.0", Destination: "vendor/cert-manager"},
+	}}
+
+	l.Merge([]LockedChart{
+		{Name: "cert-manager", Version: "v1.19.1", Destination: "vendor/cert-manager"},
+		{Name: "redis", Version: "20.0.0", Destination: "vendor/a/redis"},
+	}, []VendorChart{{Name: "traefik"}, {Name: "cert-manager"}, {Name: "redis"}})
+
+	require.Equal(t, []LockedChart{
+		{Name: "redis", Version: "20.0.0", Destination: "vendor/a/redis"},
+		{Name: "cert-manager", Version: "v1.19.1", Destination: "vendor/cert-manager"},
+		{Name: "traefik", Version: "37.0.0", Destination: "vendor/traefik"},
+	}, l.Charts)
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// MarkerFile is the name of the marker file written into the destination of extracted charts.
+const MarkerFile = ".vendor.json"
+
+// Marker records where the content of a destination was vendored from, for other tools and humans browsing the repository.
+type Marker struct {
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	Repository     string `json:"repository"`
+	URL            string `json:"url,omitempty"`
+	Commit         string `json:"commit,omitempty"`
+	Digest         string `json:"digest,omitempty"`
+	RepackedDigest string `json:"repackedDigest,omitempty"`
+	SignedBy       string `json:"signedBy,omitempty"`
+	// VendoredBy is the name and version of the plugin that vendored the chart.
+	VendoredBy string `json:"vendoredBy"`
+}
+
+// MarkerPath returns the path of the marker file of a vendored chart: inside the destination directory of
+// extracted charts, or next to the archive, named after it, for packaged charts sharing a directory.
+func MarkerPath(destination string, extract bool) string {
+	if extract {
+		return filepath.Join(destination, MarkerFile)
+	}
+
+	return destination + MarkerFile
+}
+
+// ReadMarker reads the marker file at path.
+// Returns the marker or an error if any, which wraps fs.ErrNotExist when there is no marker file.
+func ReadMarker(path string) (*Marker, error) {
+	src, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read marker file: %w", err)
+	}
+
+	var m Marker
+
+	err = json.Unmarshal(src, &m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse marker file %s: %w", path, err)
+	}
+
+	return &m, nil
+}
+
+// Write writes the marker to path as indented json.
+func (m *Marker) Write(path string) error {
+	out, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to render marker file: %w", err)
+	}
+
+	//nolint:gosec // G306 the marker is meant to be read by other tools.
+	err = os.WriteFile(path, append(out, '\n'), 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write marker file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkerPath(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, filepath.Join("vendor", "traefik", ".vendor.json"), MarkerPath(filepath.Join("vendor", "traefik"), true))
+	require.Equal(t, "vendor/traefik-37.0.0.tgz.vendor.json", MarkerPath("vendor/traefik-37.0.0.tgz", false))
+}
+
+func TestMarker_ReadWrite(t *testing.T) {
+	t.Parallel()
+
+	p := filepath.Join(t.TempDir(), MarkerFile)
+
+	_, err := ReadMarker(p)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	m := &Marker{
+		Name:       "traefik",
+		Version:    "37.0.0",
+		Repository: "https://traefik.github.io/charts",
+		URL:        "https://traefik.github.io/charts/traefik-37.0.0.tgz",
+		Digest:     "sha256:abc",
+		SignedBy:   "Traefik Labs <charts@traefik.io>",
+		VendoredBy: "helm-vendor-plugin v1.2.0",
+	}
+
+	require.NoError(t, m.Write(p))
+
+	got, err := ReadMarker(p)
+	require.NoError(t, err)
+	require.Equal(t, m, got)
+
+	require.NoError(t, os.WriteFile(p, []byte("{"), 0o600))
+
+	_, err = ReadMarker(p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to parse marker file")
+}
@@ -1,8 +1,8 @@
 package config
 
 import (
-	"errors"
 	"fmt"
+	"os"
 
 	"github.com/kaptinlin/jsonschema"
 
@@ -33,37 +33,68 @@ type JSONConfigParser struct {
 }
 
 // Unmarshall parses the given vendor-charts configuration after validating it against the JSON schema.
+// Environment variable references are expanded before validation.
 // It returns the parsed VendorChart slice or an error if validation or unmarshalling fails.
 func (j *JSONConfigParser) Unmarshall(cfg []byte) ([]VendorChart, error) {
-	err := j.Validate(cfg)
+	doc, err := j.unmarshallDocument(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	vcs := struct {
-		Charts []VendorChart `json:"charts"`
-	}{}
+	return doc.Charts, nil
+}
+
+// unmarshallDocument expands, validates and parses a single configuration file, including its include list.
+func (j *JSONConfigParser) unmarshallDocument(cfg []byte) (*document, error) {
+	cfg, err := expandEnv(cfg, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	err = j.validateSchema(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	err = j.schema.Unmarshal(&vcs, cfg)
+	doc := document{}
+
+	err = j.schema.Unmarshal(&doc, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("unable to unmarshal configuration: %w", err)
 	}
 
-	return vcs.Charts, nil
+	var ds Diagnostics
+
+	for i := range doc.Charts {
+		ds = append(ds, doc.Charts[i].validateTemplates(i)...)
+	}
+
+	if len(ds) > 0 {
+		ds.sort()
+
+		return nil, ds
+	}
+
+	return &doc, nil
 }
 
-// Validate checks the structural integrity of the configuration file against the JSON schema.
-// It returns a detailed error message listing all validation failures, or nil if valid.
+// Validate checks the structural integrity of the configuration file against the JSON schema,
+// after expanding environment variable references.
+// It returns the validation failures as Diagnostics, or nil if valid.
 func (j *JSONConfigParser) Validate(cfg []byte) error {
-	r := j.schema.ValidateJSON(cfg)
-	errMsg := "invalid configuration file:"
+	cfg, err := expandEnv(cfg, os.LookupEnv)
+	if err != nil {
+		return err
+	}
 
-	if !r.IsValid() {
-		for field, err := range r.Errors {
-			errMsg = fmt.Sprintf("%s\n- %s: %s", errMsg, field, err.Message)
-		}
+	return j.validateSchema(cfg)
+}
 
-		return errors.New(errMsg) //nolint:err113 // We want a dynamic error here to preserve the keys for user exp
+// validateSchema checks the given, already expanded, configuration against the JSON schema.
+func (j *JSONConfigParser) validateSchema(cfg []byte) error {
+	r := j.schema.ValidateJSON(cfg)
+	if !r.IsValid() {
+		return schemaDiagnostics(r, cfg)
 	}
 
 	return nil
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	errUnknownChart   = errors.New("unknown chart")
+	errNoChartMatches = errors.New("no chart matches the selection")
+)
+
+// Select returns the charts matching any of the given names or tags, in their original order.
+// Without names and tags every chart is selected.
+// Returns an error if a name is not declared in the configuration or nothing matches.
+func Select(vcs []VendorChart, names, tags []string) ([]VendorChart, error) {
+	if len(names) == 0 && len(tags) == 0 {
+		return vcs, nil
+	}
+
+	var unknown []string
+
+	for _, n := range names {
+		if !slices.ContainsFunc(vcs, func(vc VendorChart) bool { return vc.Name == n }) {
+			unknown = append(unknown, n)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: %s", errUnknownChart, strings.Join(unknown, ", "))
+	}
+
+	selected := make([]VendorChart, 0, len(vcs))
+
+	for _, vc := range vcs {
+		if slices.Contains(names, vc.Name) || slices.ContainsFunc(vc.Tags, func(t string) bool { return slices.Contains(tags, t) }) {
+			selected = append(selected, vc)
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("%w: tags %s", errNoChartMatches, strings.Join(tags, ", "))
+	}
+
+	return selected, nil
+}
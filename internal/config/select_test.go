@@ -0,0 +1,88 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+
+	vcs := []VendorChart{
+		{Name: "prometheus", Tags: []string{"monitoring"}},
+		{Name: "grafana", Tags: []string{"monitoring", "dashboards"}},
+		{Name: "ingress-nginx", Tags: []string{"ingress"}},
+		{Name: "cert-manager"},
+	}
+
+	tests := []struct {
+		name    string
+		names   []string
+		tags    []string
+		want    []string
+		errMsg  string
+		wantErr bool
+	}{
+		{
+			name:    "no selection",
+			want:    []string{"prometheus", "grafana", "ingress-nginx", "cert-manager"},
+			wantErr: false,
+		},
+		{
+			name:    "by name",
+			names:   []string{"cert-manager", "prometheus"},
+			want:    []string{"prometheus", "cert-manager"},
+			wantErr: false,
+		},
+		{
+			name:    "by tag",
+			tags:    []string{"monitoring"},
+			want:    []string{"prometheus", "grafana"},
+			wantErr: false,
+		},
+		{
+			name:    "by name and tag",
+			names:   []string{"cert-manager"},
+			tags:    []string{"ingress"},
+			want:    []string{"ingress-nginx", "cert-manager"},
+			wantErr: false,
+		},
+		{
+			name:    "unknown name",
+			names:   []string{"loki", "prometheus", "tempo"},
+			wantErr: true,
+			errMsg:  "unknown chart: loki, tempo",
+		},
+		{
+			name:    "unmatched tag",
+			tags:    []string{"storage"},
+			wantErr: true,
+			errMsg:  "no chart matches the selection: tags storage",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Select(vcs, tt.names, tt.tags)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			names := make([]string, 0, len(got))
+			for _, vc := range got {
+				names = append(names, vc.Name)
+			}
+
+			require.Equal(t, tt.want, names)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	errDuplicateChart      = errors.New("duplicated chart")
+	errDestinationConflict = errors.New("conflicting destinations")
+	errNestedDestination   = errors.New("nested destinations")
+	errDestinationEscapes  = errors.New("destination escapes the repository root")
+)
+
+// ValidateSemantics checks the relations between the given charts that the JSON schema cannot express:
+// duplicated name and version pairs, charts writing to the same files, destinations nested inside an
+// extracted chart and destinations pointing outside of the root directory.
+// Returns every problem as Diagnostics, or nil if there is none.
+func ValidateSemantics(vcs []VendorChart, root string) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return fmt.Errorf("unable to resolve root directory: %w", err)
+	}
+
+	var ds Diagnostics
+
+	dests := make([]string, len(vcs))
+	seen := map[string]int{}
+
+	for i := range vcs {
+		d, err := vcs[i].RenderDestination(NewTemplateData(&vcs[i], "0.0.0"))
+		if err != nil {
+			ds = append(ds, vcs[i].diagnostic(KindTemplate, "destination", err))
+			continue
+		}
+
+		dests[i] = filepath.Clean(d)
+		if !filepath.IsAbs(dests[i]) {
+			dests[i] = filepath.Join(root, dests[i])
+		}
+
+		if dests[i] != root && !isSubPath(root, dests[i]) {
+			ds = append(ds, vcs[i].diagnostic(KindEscape, "destination",
+				fmt.Errorf("%w: %s writes to %s", errDestinationEscapes, vcs[i].describe(), d)))
+		}
+
+		// Charts keeping their top-level directory only own the directory named after them.
+		dests[i] = vcs[i].ChartDir(dests[i])
+
+		key := vcs[i].Name + "@" + vcs[i].Version
+		if j, ok := seen[key]; ok {
+			ds = append(ds, vcs[i].diagnostic(KindDuplicate, "name",
+				fmt.Errorf("%w: %s is also declared as %s", errDuplicateChart, vcs[i].describe(), vcs[j].describe())))
+		} else {
+			seen[key] = i
+		}
+	}
+
+	for i := range vcs {
+		for j := i + 1; j < len(vcs); j++ {
+			// Destinations depending on the appVersion are only known once the chart is downloaded.
+			if dests[i] == "" || dests[j] == "" || usesAppVersion(vcs[i].Destination) || usesAppVersion(vcs[j].Destination) {
+				continue
+			}
+
+			if d, ok := checkDestinations(&vcs[i], &vcs[j], dests[i], dests[j]); ok {
+				ds = append(ds, d)
+			}
+		}
+	}
+
+	if len(ds) > 0 {
+		ds.sort()
+
+		return ds
+	}
+
+	return nil
+}
+
+// checkDestinations reports if two charts would overwrite each other's files.
+// An extracted chart owns its whole destination directory,
+// while archives only own their file, so they may share a directory.
+// The diagnostic is attached to the chart declared later.
+func checkDestinations(a, b *VendorChart, da, db string) (Diagnostic, bool) {
+	if da == db {
+		if a.Extract || b.Extract || (a.archiveName() == b.archiveName() && !usesAppVersion(a.Filename+b.Filename)) {
+			return b.diagnostic(KindConflict, "destination",
+				fmt.Errorf("%w: %s and %s both write to %s", errDestinationConflict, a.describe(), b.describe(), a.Destination)), true
+		}
+
+		return Diagnostic{}, false
+	}
+
+	if a.Extract && isSubPath(da, db) {
+		return b.diagnostic(KindNested, "destination",
+			fmt.Errorf("%w: %s writes inside the extracted destination of %s", errNestedDestination, b.describe(), a.describe())), true
+	}
+
+	if b.Extract && isSubPath(db, da) {
+		return b.diagnostic(KindNested, "destination",
+			fmt.Errorf("%w: %s writes inside the extracted destination of %s", errNestedDestination, a.describe(), b.describe())), true
+	}
+
+	return Diagnostic{}, false
+}
+
+// diagnostic creates a Diagnostic pointing to the given field of the chart in its source file.
+func (vc *VendorChart) diagnostic(kind, field string, err error) Diagnostic {
+	return Diagnostic{
+		Kind:    kind,
+		File:    vc.Source,
+		Path:    fmt.Sprintf("/charts/%d/%s", vc.Index, field),
+		Message: err.Error(),
+	}
+}
+
+// isSubPath reports whether child is located inside parent, both must be absolute.
+func isSubPath(parent, child string) bool {
+	rel, err := filepath.Rel(parent, child)
+	if err != nil {
+		return false
+	}
+
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// usesAppVersion reports whether the template depends on the appVersion of the chart, which is only known once the
+// chart is downloaded, so its rendering with a placeholder can't be compared with other charts.
+func usesAppVersion(tmpl string) bool {
+	return strings.Contains(tmpl, ".AppVersion")
+}
+
+// archiveName returns the rendered archive filename used for conflict detection.
+func (vc *VendorChart) archiveName() string {
+	fn, _ := vc.RenderFilename(NewTemplateData(vc, "0.0.0"))
+
+	return fn
+}
+
+// describe returns a short human readable reference to the chart for error messages.
+func (vc *VendorChart) describe() string {
+	if vc.Source == "" {
+		return vc.Name + "@" + vc.Version
+	}
+
+	return vc.Name + "@" + vc.Version + " (" + vc.Source + ")"
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSemantics(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		vcs     []VendorChart
+		errMsgs []string
+		wantErr bool
+	}{
+		{
+			name: "archives sharing a directory",
+			vcs: []VendorChart{
+				{Name: "a", Version: "1.0.0", Destination: "vendor"},
+				{Name: "b", Version: "1.0.0", Destination: "vendor/"},
+				{Name: "a", Version: "2.0.0", Destination: "./vendor"},
+				{Name: "c", Version: "1.0.0", Destination: "vendor/c", Extract: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "duplicated chart",
+			vcs: []VendorChart{
+				{Name: "a", Version: "1.0.0", Destination: "vendor/a", Source: "team-a.yaml"},
+				{Name: "a", Version: "1.0.0", Destination: "vendor/b", Source: "team-b.yaml"},
+			},
+			wantErr: true,
+			errMsgs: []string{"duplicated chart: a@1.0.0 (team-b.yaml) is also declared as a@1.0.0 (team-a.yaml)"},
+		},
+		{
+			name: "same destination",
+			vcs: []VendorChart{
+				{Name: "a", Version: "1.0.0", Destination: "vendor/a", Extract: true},
+				{Name: "b", Version: "1.0.0", Destination: "vendor/a"},
+				{Name: "c", Version: "1.0.0", Destination: "vendor/c", Filename: "chart.tgz"},
+				{Name: "d", Version: "1.0.0", Destination: "vendor/c", Filename: "chart.tgz"},
+			},
+			wantErr: true,
+			errMsgs: []string{
+				"conflicting destinations: a@1.0.0 and b@1.0.0 both write to vendor/a",
+				"conflicting destinations: c@1.0.0 and d@1.0.0 both write to vendor/c",
+			},
+		},
+		{
+			name: "nested destination",
+			vcs: []VendorChart{
+				{Name: "a", Version: "1.0.0", Destination: "vendor/a/charts/b"},
+				{Name: "b", Version: "1.0.0", Destination: "vendor/{{.Name}}", Extract: true},
+				{Name: "c", Version: "1.0.0", Destination: "vendor/b/sub"},
+			},
+			wantErr: true,
+			errMsgs: []string{"nested destinations: c@1.0.0 writes inside the extracted destination of b@1.0.0"},
+		},
+		{
+			name: "charts keeping their top-level directory",
+			vcs: []VendorChart{
+				{Name: "a", Version: "1.0.0", Destination: "vendor", Extract: true, KeepTopLevel: true},
+				{Name: "b", Version: "1.0.0", Destination: "vendor", Extract: true, KeepTopLevel: true},
+				{Name: "c", Version: "1.0.0", Destination: "vendor"},
+				{Name: "d", Version: "1.0.0", Destination: "vendor/a/charts/d"},
+			},
+			wantErr: true,
+			errMsgs: []string{"nested destinations: d@1.0.0 writes inside the extracted destination of a@1.0.0"},
+		},
+		{
+			name: "destinations depending on the appVersion",
+			vcs: []VendorChart{
+				{Name: "a", Version: "1.0.0", Destination: "vendor/{{.Name}}-{{.AppVersion}}", Extract: true},
+				{Name: "a", Version: "2.0.0", Destination: "vendor/{{.Name}}-{{.AppVersion}}", Extract: true},
+				{Name: "b", Version: "1.0.0", Destination: "vendor", Filename: "{{.Name}}-{{.AppVersion}}.tgz"},
+				{Name: "b", Version: "2.0.0", Destination: "vendor", Filename: "{{.Name}}-{{.AppVersion}}.tgz"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "escaping destination",
+			vcs: []VendorChart{
+				{Name: "a", Version: "1.0.0", Destination: "../../etc"},
+				{Name: "b", Version: "1.0.0", Destination: "/etc"},
+				{Name: "c", Version: "1.0.0", Destination: "vendor/../../c"},
+			},
+			wantErr: true,
+			errMsgs: []string{
+				"destination escapes the repository root: a@1.0.0 writes to ../../etc",
+				"destination escapes the repository root: b@1.0.0 writes to /etc",
+				"destination escapes the repository root: c@1.0.0 writes to vendor/../../c",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateSemantics(tt.vcs, t.TempDir())
+
+			if tt.wantErr {
+				require.Error(t, err)
+
+				for _, m := range tt.errMsgs {
+					require.Contains(t, err.Error(), m)
+				}
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
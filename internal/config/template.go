@@ -0,0 +1,115 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// DefaultFilename is the archive filename template used when a chart does not define one.
+const DefaultFilename = "{{.Name}}-{{.Version}}.tgz"
+
+var (
+	errEmptyTemplateResult = errors.New("template rendered to an empty string")
+	errFilenameSeparator   = errors.New("filename must not contain path separators")
+)
+
+// TemplateRepository holds the repository related fields available in path templates.
+type TemplateRepository struct {
+	URL  string
+	Host string
+}
+
+// TemplateData is the data passed to the destination and filename templates of a VendorChart.
+type TemplateData struct {
+	Name       string
+	Version    string
+	AppVersion string
+	Repository TemplateRepository
+}
+
+// NewTemplateData builds the template data for the given chart.
+// The appVersion is only known after the chart has been downloaded, so it is passed separately.
+func NewTemplateData(vc *VendorChart, appVersion string) TemplateData {
+	td := TemplateData{
+		Name:       vc.Name,
+		Version:    vc.Version,
+		AppVersion: appVersion,
+		Repository: TemplateRepository{URL: vc.Repository},
+	}
+
+	if u, err := url.Parse(vc.Repository); err == nil {
+		td.Repository.Host = u.Host
+	}
+
+	return td
+}
+
+// RenderDestination executes the destination template of the chart with the given data.
+// Returns the rendered path or an error if any.
+func (vc *VendorChart) RenderDestination(td TemplateData) (string, error) {
+	return renderTemplate("destination", vc.Destination, td)
+}
+
+// RenderFilename executes the filename template of the chart with the given data,
+// falling back to DefaultFilename when no filename is configured.
+// Returns the rendered filename or an error if any.
+func (vc *VendorChart) RenderFilename(td TemplateData) (string, error) {
+	fn := vc.Filename
+	if fn == "" {
+		fn = DefaultFilename
+	}
+
+	name, err := renderTemplate("filename", fn, td)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%w: %s", errFilenameSeparator, name)
+	}
+
+	return name, nil
+}
+
+// validateTemplates checks that the destination and filename templates of the chart at the given index
+// parse and render with placeholder data, so typos are reported at parse time.
+func (vc *VendorChart) validateTemplates(idx int) Diagnostics {
+	var ds Diagnostics
+
+	td := NewTemplateData(vc, "0.0.0")
+
+	if _, err := vc.RenderDestination(td); err != nil {
+		ds = append(ds, Diagnostic{Kind: KindTemplate, Path: fmt.Sprintf("/charts/%d/destination", idx), Message: err.Error()})
+	}
+
+	if _, err := vc.RenderFilename(td); err != nil {
+		ds = append(ds, Diagnostic{Kind: KindTemplate, Path: fmt.Sprintf("/charts/%d/filename", idx), Message: err.Error()})
+	}
+
+	return ds
+}
+
+// renderTemplate parses and executes a single text template.
+func renderTemplate(field, text string, td TemplateData) (string, error) {
+	t, err := template.New(field).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", field, err)
+	}
+
+	var buf bytes.Buffer
+
+	err = t.Execute(&buf, td)
+	if err != nil {
+		return "", fmt.Errorf("unable to render %s template: %w", field, err)
+	}
+
+	if buf.Len() == 0 {
+		return "", fmt.Errorf("%s: %w", field, errEmptyTemplateResult)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVendorChart_RenderDestination(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		vc         VendorChart
+		appVersion string
+		want       string
+		errMsg     string
+		wantErr    bool
+	}{
+		{
+			name:    "literal path",
+			vc:      VendorChart{Name: "traefik", Version: "37.4.0", Destination: "artifacts/traefik"},
+			want:    "artifacts/traefik",
+			wantErr: false,
+		},
+		{
+			name: "all placeholders",
+			vc: VendorChart{
+				Name:        "traefik",
+				Repository:  "oci://ghcr.io/traefik/helm",
+				Version:     "37.4.0",
+				Destination: "vendor/{{.Repository.Host}}/{{.Name}}/{{.Version}}-{{.AppVersion}}",
+			},
+			appVersion: "v3.5.0",
+			want:       "vendor/ghcr.io/traefik/37.4.0-v3.5.0",
+			wantErr:    false,
+		},
+		{
+			name:    "unknown field",
+			vc:      VendorChart{Name: "traefik", Version: "37.4.0", Destination: "{{.Chart}}"},
+			wantErr: true,
+			errMsg:  "unable to render destination template",
+		},
+		{
+			name:    "invalid syntax",
+			vc:      VendorChart{Name: "traefik", Version: "37.4.0", Destination: "{{.Name"},
+			wantErr: true,
+			errMsg:  "invalid destination template",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.vc.RenderDestination(NewTemplateData(&tt.vc, tt.appVersion))
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVendorChart_RenderFilename(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		vc      VendorChart
+		want    string
+		errMsg  string
+		wantErr bool
+	}{
+		{
+			name:    "default filename",
+			vc:      VendorChart{Name: "traefik", Version: "37.4.0"},
+			want:    "traefik-37.4.0.tgz",
+			wantErr: false,
+		},
+		{
+			name:    "custom filename",
+			vc:      VendorChart{Name: "traefik", Version: "37.4.0", Filename: "{{.Name}}.tgz"},
+			want:    "traefik.tgz",
+			wantErr: false,
+		},
+		{
+			name:    "filename with path separator",
+			vc:      VendorChart{Name: "traefik", Version: "37.4.0", Filename: "{{.Name}}/{{.Version}}.tgz"},
+			wantErr: true,
+			errMsg:  "filename must not contain path separators",
+		},
+		{
+			name:    "empty result",
+			vc:      VendorChart{Name: "traefik", Version: "37.4.0", Filename: "{{.AppVersion}}"},
+			wantErr: true,
+			errMsg:  "template rendered to an empty string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.vc.RenderFilename(NewTemplateData(&tt.vc, ""))
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -1,13 +1,115 @@
 // Package config responsible for configuration loading and validation
 package config
 
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Modes of resolving the dependencies of an extracted chart, see VendorChart.Dependencies.
+const (
+	// DependenciesNone keeps the chart's `charts/` directory as it was published, it is the default.
+	DependenciesNone = "none"
+	// DependenciesBuild downloads the dependencies pinned in the chart's Chart.lock, like `helm dependency build`.
+	DependenciesBuild = "build"
+	// DependenciesUpdate resolves the dependencies from Chart.yaml again, like `helm dependency update`.
+	DependenciesUpdate = "update"
+)
+
+// DefaultPatches is the directory local modifications of extracted charts are saved to, when a chart does not define one.
+const DefaultPatches = "patches"
+
+const (
+	// localRepositoryPrefix is the scheme of local chart repositories.
+	localRepositoryPrefix = "file://"
+	// gitRepositoryPrefix prefixes the URL of git chart repositories.
+	gitRepositoryPrefix = "git+"
+)
+
 // VendorChart describes a chart's properties described in the configuration file.
 type VendorChart struct {
-	Name        string `json:"name"`
-	Repository  string `json:"repository"`
-	Version     string `json:"version"`
-	Destination string `json:"destination"`
-	Insecure    bool   `json:"insecure"`
-	Verify      bool   `json:"verify"`
-	Extract     bool   `json:"extract"`
+	Name         string   `json:"name"`
+	Repository   string   `json:"repository"`
+	Version      string   `json:"version"`
+	Destination  string   `json:"destination"`
+	Filename     string   `json:"filename"`
+	Insecure     bool     `json:"insecure"`
+	Verify       bool     `json:"verify"`
+	Extract      bool     `json:"extract"`
+	Dereference  bool     `json:"dereference"`
+	Normalize    bool     `json:"normalize"`
+	KeepTopLevel bool     `json:"keepTopLevel"`
+	SubPath      string   `json:"subPath"`
+	Repack       bool     `json:"repack"`
+	Marker       bool     `json:"marker"`
+	Patches      string   `json:"patches"`
+	Ref          string   `json:"ref"`
+	Path         string   `json:"path"`
+	Dependencies string   `json:"dependencies"`
+	Include      []string `json:"include"`
+	Exclude      []string `json:"exclude"`
+	Tags         []string `json:"tags"`
+
+	// Source is the configuration file the chart was declared in.
+	Source string `json:"-"`
+	// Index is the position of the chart in the charts list of its Source.
+	Index int `json:"-"`
+}
+
+// LocalPath returns the local directory the chart is vendored from, see LocalRepositoryPath.
+func (vc *VendorChart) LocalPath() (string, bool) {
+	return LocalRepositoryPath(vc.Repository)
+}
+
+// LocalRepositoryPath returns the local directory of a repository that is a `file://` URL
+// or a plain path (absolute, or starting with `./` or `../`).
+// The second return value is false for remote repositories.
+func LocalRepositoryPath(repository string) (string, bool) {
+	if p, ok := strings.CutPrefix(repository, localRepositoryPrefix); ok {
+		return p, true
+	}
+
+	if filepath.IsAbs(repository) || strings.HasPrefix(repository, "./") || strings.HasPrefix(repository, "../") {
+		return repository, true
+	}
+
+	return "", false
+}
+
+// PatchPath returns the path of the patch file the local modifications of the extracted chart are saved to,
+// named after the chart and its vendored version in its patches directory, which defaults to DefaultPatches.
+// The version tells apart the patches of the same chart vendored at several versions.
+func (vc *VendorChart) PatchPath(version string) string {
+	dir := vc.Patches
+	if dir == "" {
+		dir = DefaultPatches
+	}
+
+	return filepath.Join(dir, vc.Name+"-"+version+".patch")
+}
+
+// ChartDir returns the directory the extracted chart is written to, given its rendered destination: the destination
+// itself, or the directory named after the chart inside it when the top-level directory of the archive is kept,
+// so several charts can share a destination.
+func (vc *VendorChart) ChartDir(dest string) string {
+	if vc.Extract && vc.KeepTopLevel {
+		return filepath.Join(dest, vc.Name)
+	}
+
+	return dest
+}
+
+// GitURL returns the URL to clone the chart's git repository from, when the repository is
+// a `git+https://` or `git+file://` URL. The second return value is false for other repositories.
+func (vc *VendorChart) GitURL() (string, bool) {
+	return strings.CutPrefix(vc.Repository, gitRepositoryPrefix)
+}
+
+// IsArchive reports whether the repository is the direct URL of a chart archive rather than a chart repository,
+// which is an http(s) URL whose path ends with `.tgz`.
+func (vc *VendorChart) IsArchive() bool {
+	u, err := url.Parse(vc.Repository)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && strings.HasSuffix(u.Path, ".tgz")
 }
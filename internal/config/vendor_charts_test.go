@@ -0,0 +1,115 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVendorChart_LocalPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		repository string
+		want       string
+		wantLocal  bool
+	}{
+		{name: "file url", repository: "file://../charts", want: "../charts", wantLocal: true},
+		{name: "absolute file url", repository: "file:///srv/charts", want: "/srv/charts", wantLocal: true},
+		{name: "absolute path", repository: "/srv/charts", want: "/srv/charts", wantLocal: true},
+		{name: "relative path", repository: "./charts", want: "./charts", wantLocal: true},
+		{name: "parent path", repository: "../../charts/api", want: "../../charts/api", wantLocal: true},
+		{name: "https repository", repository: "https://charts.jetstack.io", wantLocal: false},
+		{name: "oci repository", repository: "oci://ghcr.io/traefik/helm", wantLocal: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vc := VendorChart{Repository: tt.repository}
+
+			got, ok := vc.LocalPath()
+			require.Equal(t, tt.wantLocal, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVendorChart_IsArchive(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		repository string
+		want       bool
+	}{
+		{name: "archive url", repository: "https://example.com/charts/foo-1.2.3.tgz", want: true},
+		{name: "archive url with query", repository: "http://example.com/foo-1.2.3.tgz?token=abc", want: true},
+		{name: "helm repository", repository: "https://example.com/charts", want: false},
+		{name: "oci repository", repository: "oci://ghcr.io/foo.tgz", want: false},
+		{name: "local archive", repository: "./foo-1.2.3.tgz", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vc := VendorChart{Repository: tt.repository}
+			require.Equal(t, tt.want, vc.IsArchive())
+		})
+	}
+}
+
+func TestVendorChart_ChartDir(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		vc   VendorChart
+		want string
+	}{
+		{name: "extracted chart", vc: VendorChart{Name: "traefik", Extract: true}, want: "vendor"},
+		{
+			name: "top-level directory kept",
+			vc:   VendorChart{Name: "traefik", Extract: true, KeepTopLevel: true},
+			want: filepath.Join("vendor", "traefik"),
+		},
+		{name: "chart archive", vc: VendorChart{Name: "traefik"}, want: "vendor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.want, tt.vc.ChartDir("vendor"))
+		})
+	}
+}
+
+func TestVendorChart_PatchPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		vc   VendorChart
+		want string
+	}{
+		{name: "default directory", vc: VendorChart{Name: "traefik"}, want: filepath.Join("patches", "traefik-37.0.0.patch")},
+		{
+			name: "configured directory",
+			vc:   VendorChart{Name: "traefik", Patches: "hack/patches"},
+			want: filepath.Join("hack", "patches", "traefik-37.0.0.patch"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.want, tt.vc.PatchPath("37.0.0"))
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// SchemaURL is the public location of the configuration JSON schema, referenced by generated files.
+const SchemaURL = "https://raw.githubusercontent.com/Shikachuu/helm-vendor-plugin/refs/heads/main/schema.json"
+
+// MarshalYAML renders the given charts as a vendor-charts yaml configuration file.
+// Charts without a repository get a TODO placeholder, which fails validation until it is filled in.
+func MarshalYAML(vcs []VendorChart) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("# yaml-language-server: $schema=" + SchemaURL + "\n")
+	b.WriteString("charts:\n")
+
+	for i := range vcs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		writeChartYAML(&b, &vcs[i], "  ")
+	}
+
+	return b.Bytes()
+}
+
+// writeChartYAML writes a single chart as a yaml sequence item, every line prefixed by indent.
+// Only fields with non default values are written.
+func writeChartYAML(b *bytes.Buffer, vc *VendorChart, indent string) {
+	b.WriteString(indent + "- name: " + strconv.Quote(vc.Name) + "\n")
+
+	if vc.Repository == "" {
+		b.WriteString(indent + "  repository: TODO # the repository serving this chart could not be guessed\n")
+	} else {
+		b.WriteString(indent + "  repository: " + strconv.Quote(vc.Repository) + "\n")
+	}
+
+	b.WriteString(indent + "  version: " + strconv.Quote(vc.Version) + "\n")
+	b.WriteString(indent + "  destination: " + strconv.Quote(vc.Destination) + "\n")
+
+	if vc.Filename != "" {
+		b.WriteString(indent + "  filename: " + strconv.Quote(vc.Filename) + "\n")
+	}
+
+	if vc.Ref != "" {
+		b.WriteString(indent + "  ref: " + strconv.Quote(vc.Ref) + "\n")
+	}
+
+	if vc.Path != "" {
+		b.WriteString(indent + "  path: " + strconv.Quote(vc.Path) + "\n")
+	}
+
+	if vc.Patches != "" {
+		b.WriteString(indent + "  patches: " + strconv.Quote(vc.Patches) + "\n")
+	}
+
+	if vc.SubPath != "" {
+		b.WriteString(indent + "  subPath: " + strconv.Quote(vc.SubPath) + "\n")
+	}
+
+	if vc.Dependencies != "" && vc.Dependencies != DependenciesNone {
+		b.WriteString(indent + "  dependencies: " + vc.Dependencies + "\n")
+	}
+
+	for _, f := range []struct {
+		name  string
+		value bool
+	}{
+		{"insecure", vc.Insecure},
+		{"verify", vc.Verify},
+		{"extract", vc.Extract},
+		{"dereference", vc.Dereference},
+		{"normalize", vc.Normalize},
+		{"keepTopLevel", vc.KeepTopLevel},
+		{"repack", vc.Repack},
+		{"marker", vc.Marker},
+	} {
+		if f.value {
+			b.WriteString(indent + "  " + f.name + ": true\n")
+		}
+	}
+
+	for _, l := range []struct {
+		name   string
+		values []string
+	}{{"include", vc.Include}, {"exclude", vc.Exclude}, {"tags", vc.Tags}} {
+		if len(l.values) == 0 {
+			continue
+		}
+
+		b.WriteString(indent + "  " + l.name + ":\n")
+
+		for _, v := range l.values {
+			b.WriteString(indent + "    - " + strconv.Quote(v) + "\n")
+		}
+	}
+}
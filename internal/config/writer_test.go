@@ -0,0 +1,69 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestMarshalYAML(t *testing.T) {
+	t.Parallel()
+
+	vcs := []VendorChart{
+		{
+			Name:        "prometheus",
+			Repository:  "https://prometheus-community.github.io/helm-charts",
+			Version:     "1.10",
+			Destination: "vendor/monitoring",
+			Filename:    "prom.tgz",
+			Repack:      true,
+			Marker:      true,
+			Tags:        []string{"monitoring"},
+		},
+		{
+			Name:        "ingress-nginx",
+			Repository:  "oci://ghcr.io/charts",
+			Version:     "4.11.0",
+			Destination: "vendor/ingress",
+			Extract:     true,
+			Dereference: true,
+			Normalize:   true,
+			SubPath:     "crds",
+			Patches:     "hack/patches",
+			Exclude:     []string{"ci/", "*.md"},
+		},
+		{
+			Name:        "cert-manager",
+			Repository:  "git+https://github.com/cert-manager/cert-manager.git",
+			Version:     "v1.19.1",
+			Destination: "vendor/cert-manager",
+			Filename:    "cert-manager.tgz",
+			Ref:         "v1.19.1",
+			Path:        "deploy/charts/cert-manager",
+		},
+	}
+
+	out := MarshalYAML(vcs)
+
+	cfg, err := yaml.YAMLToJSON(out)
+	require.NoError(t, err)
+
+	got, err := newTestParser(t).Unmarshall(cfg)
+	require.NoError(t, err)
+
+	// The schema unmarshaller fills in defaults for omitted fields.
+	vcs[1].Filename = DefaultFilename
+	require.Equal(t, vcs, got)
+}
+
+func TestMarshalYAML_MissingRepository(t *testing.T) {
+	t.Parallel()
+
+	out := MarshalYAML([]VendorChart{{Name: "mychart", Version: "1.0.0", Destination: "vendor"}})
+	require.Contains(t, string(out), "repository: TODO # the repository serving this chart could not be guessed")
+
+	cfg, err := yaml.YAMLToJSON(out)
+	require.NoError(t, err)
+	require.Error(t, newTestParser(t).Validate(cfg))
+}
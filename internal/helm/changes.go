@@ -0,0 +1,263 @@
+package helm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/pmezard/go-difflib/difflib"
+	"helm.sh/helm/v4/pkg/getter"
+	"helm.sh/helm/v4/pkg/registry"
+)
+
+var errLocalModifications = errors.New("extracted chart has local modifications")
+
+// hashTree returns the digest of every regular file under dir by its slash separated, dir relative path.
+// The marker file is left out, it is rewritten on every download. Symlinks are not followed.
+func hashTree(dir string) (map[string]string, error) {
+	files := map[string]string{}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		if rel == config.MarkerFile {
+			return nil
+		}
+
+		files[rel], err = fileDigest(p)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to hash extracted chart: %w", err)
+	}
+
+	return files, nil
+}
+
+// changedFiles returns the sorted paths of the files that were modified, added or deleted since the locked digests.
+func changedFiles(locked, current map[string]string) []string {
+	var changed []string
+
+	for p, digest := range current {
+		if locked[p] != digest {
+			changed = append(changed, p)
+		}
+	}
+
+	for p := range locked {
+		if _, ok := current[p]; !ok {
+			changed = append(changed, p)
+		}
+	}
+
+	slices.Sort(changed)
+
+	return changed
+}
+
+// lockedChart returns the lock entry of the destination, or nil if it is not locked.
+func lockedChart(l *config.Lock, dest string) *config.LockedChart {
+	if l == nil {
+		return nil
+	}
+
+	i := slices.IndexFunc(l.Charts, func(c config.LockedChart) bool {
+		return filepath.Clean(c.Destination) == filepath.Clean(dest)
+	})
+	if i < 0 {
+		return nil
+	}
+
+	return &l.Charts[i]
+}
+
+// checkLocalChanges compares the extracted chart at dest with the file digests recorded in the lock,
+// before it is vendored again. Local modifications are saved as a patch with SavePatches, discarded with Force,
+// and refused otherwise.
+func checkLocalChanges(
+	s *Settings, getters getter.Providers, rc *registry.Client, vc *config.VendorChart, dest string, fo FetchOptions,
+) error {
+	locked := lockedChart(fo.Lock, dest)
+	if locked == nil || len(locked.Files) == 0 {
+		return nil
+	}
+
+	// A removed destination is vendored again from scratch, there is nothing left to save.
+	if _, err := os.Stat(dest); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	current, err := hashTree(dest)
+	if err != nil {
+		return err
+	}
+
+	changed := changedFiles(locked.Files, current)
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if !fo.SavePatches {
+		if fo.Force {
+			slog.Warn("discarding local modifications", "name", vc.Name, "files", changed)
+
+			return nil
+		}
+
+		return fmt.Errorf("%w: %s has modified files %s, pass --save-patches to save them or --force to discard them",
+			errLocalModifications, dest, strings.Join(changed, ", "))
+	}
+
+	pristine, err := os.MkdirTemp("", "helm-vendor-pristine-")
+	if err != nil {
+		return fmt.Errorf("unable to create pristine chart directory: %w", err)
+	}
+
+	defer func() {
+		_ = os.RemoveAll(pristine)
+	}()
+
+	err = fetchPristine(s, getters, rc, vc, locked, pristine, fo)
+	if err != nil {
+		return fmt.Errorf("unable to vendor %s %s again to save its local modifications: %w", vc.Name, locked.Version, err)
+	}
+
+	p := vc.PatchPath(locked.Version)
+
+	err = writePatch(p, dest, vc.ChartDir(pristine), changed)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("local modifications saved", "name", vc.Name, "patch", p, "files", changed)
+
+	return nil
+}
+
+// fetchPristine vendors the locked version of the extracted chart into dir, as it was before it was modified locally.
+func fetchPristine(
+	s *Settings, getters getter.Providers, rc *registry.Client, vc *config.VendorChart, locked *config.LockedChart,
+	dir string, fo FetchOptions,
+) error {
+	pvc := *vc
+	pvc.Version = locked.Version
+	pvc.Destination = dir
+	pvc.Marker = false
+
+	if locked.Commit != "" {
+		pvc.Ref = locked.Commit
+	}
+
+	return fetchChart(s, getters, rc, &pvc, &Result{Name: vc.Name}, FetchOptions{Limits: fo.Limits, Force: true})
+}
+
+// writePatch writes the differences between the pristine and the modified chart as a unified diff to p,
+// with the paths of the modified chart, so it can be applied with `git apply` from the working directory.
+// Binary files can't be diffed, they are left out with a warning.
+func writePatch(p, dest, pristine string, changed []string) error {
+	var b strings.Builder
+
+	for _, f := range changed {
+		from, fromOK, err := readPatchFile(filepath.Join(pristine, filepath.FromSlash(f)))
+		if err != nil {
+			return err
+		}
+
+		to, toOK, err := readPatchFile(filepath.Join(dest, filepath.FromSlash(f)))
+		if err != nil {
+			return err
+		}
+
+		if bytes.IndexByte(from, 0) >= 0 || bytes.IndexByte(to, 0) >= 0 {
+			slog.Warn("binary file modified locally, it is not saved in the patch", "file", path.Join(dest, f))
+
+			continue
+		}
+
+		name := path.Join(filepath.ToSlash(dest), f)
+
+		ud := difflib.UnifiedDiff{
+			A:        patchLines(from),
+			B:        patchLines(to),
+			FromFile: patchFileName("a/", name, fromOK),
+			ToFile:   patchFileName("b/", name, toOK),
+			Context:  3,
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(ud)
+		if err != nil {
+			return fmt.Errorf("unable to diff %s: %w", name, err)
+		}
+
+		b.WriteString(diff)
+	}
+
+	err := os.MkdirAll(filepath.Dir(p), 0o750)
+	if err != nil {
+		return fmt.Errorf("unable to create patch directory: %w", err)
+	}
+
+	err = os.WriteFile(p, []byte(b.String()), 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to write patch: %w", err)
+	}
+
+	return nil
+}
+
+// readPatchFile reads a file to diff, reporting whether it exists.
+func readPatchFile(p string) ([]byte, bool, error) {
+	content, err := os.ReadFile(filepath.Clean(p))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to read %s: %w", p, err)
+	}
+
+	return content, true, nil
+}
+
+// patchLines splits content into lines keeping their line endings, a missing newline at the end of the content
+// is marked the way `git apply` expects. Unlike difflib.SplitLines it doesn't add an empty line at the end.
+func patchLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	} else {
+		lines[last] += "\n\\ No newline at end of file\n"
+	}
+
+	return lines
+}
+
+// patchFileName returns the name of a file in a patch header, `/dev/null` for files that don't exist on that side.
+func patchFileName(prefix, name string, exists bool) string {
+	if !exists {
+		return "/dev/null"
+	}
+
+	return prefix + name
+}
@@ -0,0 +1,166 @@
+package helm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		locked  map[string]string
+		current map[string]string
+		want    []string
+	}{
+		{
+			name:    "unchanged",
+			locked:  map[string]string{"Chart.yaml": "sha256:1", "values.yaml": "sha256:2"},
+			current: map[string]string{"Chart.yaml": "sha256:1", "values.yaml": "sha256:2"},
+		},
+		{
+			name:    "modified, added and deleted files",
+			locked:  map[string]string{"Chart.yaml": "sha256:1", "values.yaml": "sha256:2", "templates/svc.yaml": "sha256:3"},
+			current: map[string]string{"Chart.yaml": "sha256:1", "values.yaml": "sha256:4", "templates/cm.yaml": "sha256:5"},
+			want:    []string{"templates/cm.yaml", "templates/svc.yaml", "values.yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, changedFiles(tt.locked, tt.current))
+		})
+	}
+}
+
+func TestPatchLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{name: "empty"},
+		{name: "trailing newline", content: "a\nb\n", want: []string{"a\n", "b\n"}},
+		{name: "no trailing newline", content: "a\nb", want: []string{"a\n", "b\n\\ No newline at end of file\n"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, patchLines([]byte(tt.content)))
+		})
+	}
+}
+
+func TestHashTree(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "templates"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "templates", "svc.yaml"), []byte("kind: Service\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.MarkerFile), []byte("{}\n"), 0o600))
+	require.NoError(t, os.Symlink("templates/svc.yaml", filepath.Join(dir, "svc.yaml")))
+
+	files, err := hashTree(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Contains(t, files, "templates/svc.yaml")
+}
+
+func TestFetchCharts_LocalChanges(t *testing.T) {
+	archive := createTestTarGz(t, "mychart", map[string]string{
+		"Chart.yaml":  "apiVersion: v2\nname: mychart\nversion: 1.2.3\n",
+		"values.yaml": "replicas: 1\nimage: nginx\n",
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		modify      bool
+		force       bool
+		savePatches bool
+		wantErr     string
+		wantPatch   string
+	}{
+		{
+			name: "unchanged chart",
+		},
+		{
+			name:    "modified chart",
+			modify:  true,
+			wantErr: "pass --save-patches to save them or --force to discard them",
+		},
+		{
+			name:   "modifications discarded",
+			modify: true,
+			force:  true,
+		},
+		{
+			name:        "modifications saved",
+			modify:      true,
+			savePatches: true,
+			wantPatch:   "@@ -1,2 +1,2 @@\n-replicas: 1\n+replicas: 3\n image: nginx\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "mychart")
+			patches := t.TempDir()
+			s := &Settings{ContentCache: t.TempDir()}
+			vc := config.VendorChart{
+				Name:        "mychart",
+				Repository:  server.URL + "/mychart-1.2.3.tgz",
+				Version:     "1.2.3",
+				Destination: dest,
+				Extract:     true,
+				Patches:     patches,
+			}
+
+			results, err := FetchCharts(s, []config.VendorChart{vc}, FetchOptions{})
+			require.NoError(t, err)
+			require.Len(t, results[0].Files, 2)
+
+			lock := &config.Lock{Charts: []config.LockedChart{
+				{Name: "mychart", Version: "1.2.3", Destination: dest, Files: results[0].Files},
+			}}
+
+			if tt.modify {
+				require.NoError(t, os.WriteFile(filepath.Join(dest, "values.yaml"), []byte("replicas: 3\nimage: nginx\n"), 0o600))
+			}
+
+			_, err = FetchCharts(s, []config.VendorChart{vc}, FetchOptions{Lock: lock, Force: tt.force, SavePatches: tt.savePatches})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			values, err := os.ReadFile(filepath.Join(dest, "values.yaml"))
+			require.NoError(t, err)
+			require.Equal(t, "replicas: 1\nimage: nginx\n", string(values))
+
+			patch, err := os.ReadFile(vc.PatchPath("1.2.3"))
+			if tt.wantPatch == "" {
+				require.ErrorIs(t, err, os.ErrNotExist)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			name := filepath.ToSlash(filepath.Join(dest, "values.yaml"))
+			require.Equal(t, "--- a/"+name+"\n+++ b/"+name+"\n"+tt.wantPatch, string(patch))
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package helm
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"helm.sh/helm/v4/pkg/chart/v2/loader"
+	"helm.sh/helm/v4/pkg/downloader"
+	"helm.sh/helm/v4/pkg/getter"
+	"helm.sh/helm/v4/pkg/registry"
+)
+
+// depsMu serializes dependency resolution, since it refreshes the shared repository cache.
+var depsMu sync.Mutex
+
+// vendorDependencies downloads the dependencies of the chart extracted to dir into its `charts/` directory,
+// from its Chart.lock or by resolving Chart.yaml again, depending on the chart's dependencies mode.
+// The same getters, registry client and verification strategy as for the chart itself are used.
+// Returns the vendored dependencies as recorded in the chart's Chart.lock or an error if any.
+func vendorDependencies(
+	s *Settings, getters getter.Providers, rc *registry.Client, vc *config.VendorChart, dir string,
+) ([]config.LockedDependency, error) {
+	m := &downloader.Manager{
+		Out:              os.Stderr,
+		ChartPath:        dir,
+		Verify:           getVerify(vc),
+		Debug:            s.Debug,
+		Getters:          getters,
+		RegistryClient:   rc,
+		RepositoryConfig: s.RepositoryConfig,
+		RepositoryCache:  s.RepositoryCache,
+		ContentCache:     s.ContentCache,
+	}
+
+	depsMu.Lock()
+	defer depsMu.Unlock()
+
+	var err error
+
+	if vc.Dependencies == config.DependenciesUpdate {
+		err = m.Update()
+	} else {
+		err = m.Build()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to %s chart dependencies: %w", vc.Dependencies, err)
+	}
+
+	ch, err := loader.LoadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load chart with dependencies: %w", err)
+	}
+
+	if ch.Lock == nil {
+		return nil, nil
+	}
+
+	deps := make([]config.LockedDependency, 0, len(ch.Lock.Dependencies))
+
+	for _, d := range ch.Lock.Dependencies {
+		ld := config.LockedDependency{Name: d.Name, Version: d.Version, Repository: d.Repository}
+
+		p := filepath.Join(dir, "charts", d.Name+"-"+d.Version+".tgz")
+		if _, sErr := os.Stat(p); sErr == nil {
+			ld.Digest, err = fileDigest(p)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		deps = append(deps, ld)
+	}
+
+	slog.Info("chart dependencies vendored", "name", vc.Name, "dependencies", len(deps))
+
+	return deps, nil
+}
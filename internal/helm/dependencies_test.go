@@ -0,0 +1,101 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchCharts_Dependencies(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "charts")
+	depDir := filepath.Join(tmpDir, "library", "common")
+
+	files := map[string]string{
+		filepath.Join(depDir, "Chart.yaml"): "apiVersion: v2\nname: common\nversion: 0.3.0\ntype: library\n",
+		filepath.Join(repoDir, "umbrella", "Chart.yaml"): `apiVersion: v2
+name: umbrella
+version: 1.0.0
+dependencies:
+  - name: common
+    version: 0.3.0
+    repository: file://` + depDir + `
+`,
+		filepath.Join(repoDir, "umbrella", "templates", "cm.yaml"): "kind: ConfigMap\n",
+	}
+
+	for p, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o750))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+	}
+
+	s := &Settings{
+		RepositoryConfig: filepath.Join(tmpDir, "repositories.yaml"),
+		RepositoryCache:  filepath.Join(tmpDir, "cache"),
+		ContentCache:     filepath.Join(tmpDir, "content"),
+	}
+
+	tests := []struct {
+		name         string
+		dependencies string
+		// chartDir is where the chart is extracted inside the destination.
+		chartDir     string
+		want         []config.LockedDependency
+		wantFile     bool
+		keepTopLevel bool
+	}{
+		{
+			name:         "update",
+			dependencies: config.DependenciesUpdate,
+			want:         []config.LockedDependency{{Name: "common", Version: "0.3.0", Repository: "file://" + depDir}},
+			wantFile:     true,
+		},
+		{
+			name:         "update with the top-level directory",
+			dependencies: config.DependenciesUpdate,
+			keepTopLevel: true,
+			chartDir:     "umbrella",
+			want:         []config.LockedDependency{{Name: "common", Version: "0.3.0", Repository: "file://" + depDir}},
+			wantFile:     true,
+		},
+		{
+			name:         "none",
+			dependencies: config.DependenciesNone,
+			wantFile:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "umbrella")
+			vc := config.VendorChart{
+				Name: "umbrella", Repository: repoDir, Version: "1.0.0", Destination: dest,
+				Extract: true, Dependencies: tt.dependencies, KeepTopLevel: tt.keepTopLevel,
+			}
+
+			results, err := FetchCharts(s, []config.VendorChart{vc}, FetchOptions{})
+			require.NoError(t, err)
+
+			archive := filepath.Join(dest, tt.chartDir, "charts", "common-0.3.0.tgz")
+
+			if !tt.wantFile {
+				require.Empty(t, results[0].Dependencies)
+				require.NoFileExists(t, archive)
+
+				return
+			}
+
+			require.FileExists(t, archive)
+			require.FileExists(t, filepath.Join(dest, tt.chartDir, "Chart.lock"))
+
+			digest, err := fileDigest(archive)
+			require.NoError(t, err)
+
+			tt.want[0].Digest = digest
+			require.Equal(t, tt.want, results[0].Dependencies)
+		})
+	}
+}
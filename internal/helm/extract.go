@@ -0,0 +1,301 @@
+package helm
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"helm.sh/helm/v4/pkg/ignore"
+)
+
+var (
+	errTooManyEntries  = errors.New("chart archive has too many entries")
+	errFileTooLarge    = errors.New("chart archive file is too large")
+	errArchiveTooLarge = errors.New("chart archive is too large")
+	errInvalidSubPath  = errors.New("sub-path must be a relative path inside the chart")
+	errSubPathNotFound = errors.New("sub-path is not in the chart archive")
+)
+
+// requiredChartPaths are the chart paths Helm can't render the chart without, a warning is logged when
+// the include or exclude rules of a chart filter out every file of one of them.
+var requiredChartPaths = []string{"Chart.yaml", "templates/"}
+
+const (
+	// normalizedFileMode is the mode of normalized files.
+	normalizedFileMode fs.FileMode = 0o644
+	// normalizedDirMode is the mode of normalized directories.
+	normalizedDirMode fs.FileMode = 0o755
+)
+
+// normalizedModTime is the modification time of normalized files and directories, the earliest time zip archives
+// can store, which reproducible build tools use as well.
+var normalizedModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// DefaultExtractLimits are the extraction limits of the download command, the size limits are Helm's own defaults.
+var DefaultExtractLimits = ExtractLimits{
+	TotalSize: 100 * 1024 * 1024,
+	FileSize:  5 * 1024 * 1024,
+	Entries:   10000,
+}
+
+// ExtractLimits bound the content of a chart archive, so a broken or malicious chart can't fill the disk.
+// A zero value disables the limit.
+type ExtractLimits struct {
+	// TotalSize is the maximum uncompressed size of the whole archive in bytes.
+	TotalSize int64
+	// FileSize is the maximum uncompressed size of a single file in bytes.
+	FileSize int64
+	// Entries is the maximum number of entries in the archive.
+	Entries int
+}
+
+// extractOptions controls which entries of a chart archive are extracted and how.
+type extractOptions struct {
+	// filter selects the extracted files, nil extracts every file.
+	filter *fileFilter
+	// dereference copies the targets of symlinks and hardlinks instead of creating links.
+	dereference bool
+	// normalize sets fixed modes and modification times, instead of the ones in the archive.
+	normalize bool
+	// keepTopLevel keeps the top-level directory of the archive, which is named after the chart.
+	keepTopLevel bool
+	// subPath is the chart relative, cleaned path of the only directory or file to extract.
+	subPath string
+	limits  ExtractLimits
+}
+
+// newExtractOptions builds the extraction options of a chart from its configuration and the extraction limits.
+// Returns the options or an error if any.
+func newExtractOptions(vc *config.VendorChart, limits ExtractLimits) (extractOptions, error) {
+	filter, err := newFileFilter(vc.Include, vc.Exclude)
+	if err != nil {
+		return extractOptions{}, err
+	}
+
+	opts := extractOptions{
+		filter:       filter,
+		dereference:  vc.Dereference,
+		normalize:    vc.Normalize,
+		keepTopLevel: vc.KeepTopLevel,
+		limits:       limits,
+	}
+
+	if vc.SubPath != "" {
+		opts.subPath = path.Clean(vc.SubPath)
+		if path.IsAbs(opts.subPath) || opts.subPath == "." || opts.subPath == ".." || strings.HasPrefix(opts.subPath, "../") {
+			return extractOptions{}, fmt.Errorf("%w: %s", errInvalidSubPath, vc.SubPath)
+		}
+	}
+
+	return opts, nil
+}
+
+// destPath maps an archive entry name to the path it is extracted to, relative to the destination.
+// The top-level directory is stripped unless it is kept, with a sub-path only the entries in it are extracted,
+// relative to it, while a file sub-path keeps its name.
+// Returns false for the entries that are not extracted.
+func (o extractOptions) destPath(name string, isDir bool) (string, bool) {
+	_, rel, ok := strings.Cut(name, "/")
+
+	switch {
+	case !ok:
+		return "", false
+	case o.keepTopLevel:
+		return name, true
+	case o.subPath == "":
+		return rel, true
+	}
+
+	rel = strings.TrimSuffix(rel, "/")
+
+	if rel == o.subPath {
+		if isDir {
+			return "", true
+		}
+
+		return path.Base(rel), true
+	}
+
+	return strings.CutPrefix(rel, o.subPath+"/")
+}
+
+// fileFilter selects the files to extract with include and exclude rules written in `.helmignore` syntax.
+type fileFilter struct {
+	include *ignore.Rules
+	exclude *ignore.Rules
+	// required records, for the required chart paths found in the archive, whether any of their files is extracted.
+	required map[string]bool
+}
+
+// newFileFilter parses the include and exclude rules.
+// Returns nil when there are no rules at all, or an error if a rule is invalid.
+func newFileFilter(include, exclude []string) (*fileFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	f := &fileFilter{required: map[string]bool{}}
+
+	var err error
+
+	if len(include) > 0 {
+		f.include, err = ignore.Parse(strings.NewReader(strings.Join(include, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid include rule: %w", err)
+		}
+	}
+
+	if len(exclude) > 0 {
+		f.exclude, err = ignore.Parse(strings.NewReader(strings.Join(exclude, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude rule: %w", err)
+		}
+	}
+
+	return f, nil
+}
+
+// skip reports whether the entry at the chart relative path p must not be extracted.
+// Excluded entries are always skipped, when there are include rules only the files they match are extracted.
+// Directories are never skipped because of include rules, they are created as the files in them are extracted.
+func (f *fileFilter) skip(p string, fi fs.FileInfo) bool {
+	if f == nil {
+		return false
+	}
+
+	if f.exclude != nil && matchRules(f.exclude, p, fi) {
+		return true
+	}
+
+	return f.include != nil && !fi.IsDir() && !matchRules(f.include, p, fi)
+}
+
+// track records whether the file at the chart relative path p is extracted, for the required chart paths
+// it is, or is part of. Directories don't count, an empty templates directory renders nothing.
+func (f *fileFilter) track(p string, fi fs.FileInfo, extracted bool) {
+	if f == nil || fi.IsDir() {
+		return
+	}
+
+	for _, r := range requiredChartPaths {
+		if p == r || (strings.HasSuffix(r, "/") && strings.HasPrefix(p, r)) {
+			f.required[r] = f.required[r] || extracted
+		}
+	}
+}
+
+// filteredOut returns the required chart paths found in the archive none of whose files were extracted.
+func (f *fileFilter) filteredOut() []string {
+	if f == nil {
+		return nil
+	}
+
+	var paths []string
+
+	for _, r := range requiredChartPaths {
+		if extracted, ok := f.required[r]; ok && !extracted {
+			paths = append(paths, r)
+		}
+	}
+
+	return paths
+}
+
+// matchRules reports whether the rules match the path or any of its parent directories,
+// since Helm skips the whole content of an ignored directory.
+func matchRules(r *ignore.Rules, p string, fi fs.FileInfo) bool {
+	parts := strings.Split(strings.TrimSuffix(p, "/"), "/")
+
+	for i := 1; i < len(parts); i++ {
+		if r.Ignore(strings.Join(parts[:i], "/"), dirInfo(parts[i-1])) {
+			return true
+		}
+	}
+
+	return r.Ignore(strings.TrimSuffix(p, "/"), fi)
+}
+
+// dirInfo describes the parent directories of archive entries, which might not have entries of their own.
+type dirInfo string
+
+// Name returns the base name of the directory.
+func (d dirInfo) Name() string { return string(d) }
+
+// Size returns zero, directories have no content.
+func (d dirInfo) Size() int64 { return 0 }
+
+// Mode returns the mode of an accessible directory.
+func (d dirInfo) Mode() fs.FileMode { return fs.ModeDir | 0o755 }
+
+// ModTime returns the zero time, it is unknown.
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+
+// IsDir returns true.
+func (d dirInfo) IsDir() bool { return true }
+
+// Sys returns nil.
+func (d dirInfo) Sys() any { return nil }
+
+// archiveUsage tracks the content of an archive being extracted against its limits.
+type archiveUsage struct {
+	limits  ExtractLimits
+	size    int64
+	entries int
+}
+
+// add accounts for the archive entry, returning an error naming it when it exceeds a limit.
+func (u *archiveUsage) add(header *tar.Header) error {
+	u.entries++
+	if u.limits.Entries > 0 && u.entries > u.limits.Entries {
+		return fmt.Errorf("%w: %s is entry %d, the limit is %d entries", errTooManyEntries, header.Name, u.entries, u.limits.Entries)
+	}
+
+	if u.limits.FileSize > 0 && header.Size > u.limits.FileSize {
+		return fmt.Errorf("%w: %s is %d bytes, the limit is %d bytes", errFileTooLarge, header.Name, header.Size, u.limits.FileSize)
+	}
+
+	u.size += header.Size
+	if u.limits.TotalSize > 0 && u.size > u.limits.TotalSize {
+		return fmt.Errorf("%w: reached %d bytes at %s, the limit is %d bytes", errArchiveTooLarge, u.size, header.Name, u.limits.TotalSize)
+	}
+
+	return nil
+}
+
+// normalizeTree sets the normalized mode and modification time of every file and directory under dir,
+// so extracting the same chart always yields the same tree. Symlinks are left alone.
+func normalizeTree(dir string) error {
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		mode := normalizedFileMode
+
+		switch {
+		case d.IsDir():
+			mode = normalizedDirMode
+		case !d.Type().IsRegular():
+			return nil
+		}
+
+		err = os.Chmod(p, mode)
+		if err != nil {
+			return err
+		}
+
+		return os.Chtimes(p, normalizedModTime, normalizedModTime)
+	})
+	if err != nil {
+		return fmt.Errorf("normalize extracted files: %w", err)
+	}
+
+	return nil
+}
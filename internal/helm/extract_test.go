@@ -0,0 +1,362 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// listFiles returns the slash separated paths of every regular file under dir, sorted.
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		files = append(files, filepath.ToSlash(rel))
+
+		return err
+	})
+	require.NoError(t, err)
+
+	slices.Sort(files)
+
+	return files
+}
+
+func TestExtractTarGz_Filter(t *testing.T) {
+	archive := createTestTarGz(t, "mychart", map[string]string{
+		"Chart.yaml":                   "name: mychart",
+		"values.yaml":                  "replicas: 1",
+		"README.md":                    "# mychart",
+		"ci/test-values.yaml":          "replicas: 3",
+		"templates/deploy.yaml":        "kind: Deployment",
+		"templates/NOTES.txt":          "notes",
+		"dashboards/big.json":          "{}",
+		"charts/sub/templates/cm.yaml": "kind: ConfigMap",
+	})
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+		errMsg  string
+		wantErr bool
+	}{
+		{
+			name: "no rules",
+			want: []string{
+				"Chart.yaml", "README.md", "charts/sub/templates/cm.yaml", "ci/test-values.yaml",
+				"dashboards/big.json", "templates/NOTES.txt", "templates/deploy.yaml", "values.yaml",
+			},
+		},
+		{
+			name:    "exclude directories and globs",
+			exclude: []string{"ci/", "*.md", "dashboards/"},
+			want:    []string{"Chart.yaml", "charts/sub/templates/cm.yaml", "templates/NOTES.txt", "templates/deploy.yaml", "values.yaml"},
+		},
+		{
+			name:    "include only",
+			include: []string{"Chart.yaml", "values.yaml", "templates/"},
+			want:    []string{"Chart.yaml", "charts/sub/templates/cm.yaml", "templates/NOTES.txt", "templates/deploy.yaml", "values.yaml"},
+		},
+		{
+			name:    "include and exclude",
+			include: []string{"Chart.yaml", "templates/"},
+			exclude: []string{"NOTES.txt", "charts/"},
+			want:    []string{"Chart.yaml", "templates/deploy.yaml"},
+		},
+		{
+			name:    "rooted rule",
+			exclude: []string{"/templates/NOTES.txt"},
+			want: []string{
+				"Chart.yaml", "README.md", "charts/sub/templates/cm.yaml", "ci/test-values.yaml",
+				"dashboards/big.json", "templates/deploy.yaml", "values.yaml",
+			},
+		},
+		{
+			name:    "invalid rule",
+			exclude: []string{"**/*.md"},
+			wantErr: true,
+			errMsg:  "invalid exclude rule",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := t.TempDir()
+
+			filter, err := newFileFilter(tt.include, tt.exclude)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.NoError(t, extractTarGz(bytes.NewReader(archive), dst, extractOptions{filter: filter}))
+			require.Equal(t, tt.want, listFiles(t, dst))
+		})
+	}
+}
+
+func TestFileFilter_FilteredOut(t *testing.T) {
+	archive := createTestTarGz(t, "mychart", map[string]string{
+		"Chart.yaml":                "name: mychart",
+		"values.yaml":               "replicas: 1",
+		"templates/deployment.yaml": "kind: Deployment",
+		"templates/service.yaml":    "kind: Service",
+	})
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{
+			name:    "some templates included",
+			include: []string{"Chart.yaml", "templates/deployment.yaml"},
+		},
+		{
+			name:    "some templates excluded",
+			exclude: []string{"templates/service.yaml"},
+		},
+		{
+			name:    "every template excluded",
+			exclude: []string{"templates/"},
+			want:    []string{"templates/"},
+		},
+		{
+			name:    "chart metadata not included",
+			include: []string{"templates/"},
+			want:    []string{"Chart.yaml"},
+		},
+		{
+			name:    "every yaml file excluded",
+			exclude: []string{"*.yaml"},
+			want:    []string{"Chart.yaml", "templates/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newFileFilter(tt.include, tt.exclude)
+			require.NoError(t, err)
+			require.NoError(t, extractTarGz(bytes.NewReader(archive), t.TempDir(), extractOptions{filter: filter}))
+			require.Equal(t, tt.want, filter.filteredOut())
+		})
+	}
+}
+
+func TestExtractTarGz_Limits(t *testing.T) {
+	archive := createTestTarGz(t, "mychart", map[string]string{
+		"Chart.yaml":            "name: mychart",
+		"values.yaml":           "replicas: 1",
+		"templates/deploy.yaml": "kind: Deployment",
+	})
+
+	tests := []struct {
+		name    string
+		errMsg  string
+		limits  ExtractLimits
+		wantErr bool
+	}{
+		{
+			name:   "default limits",
+			limits: DefaultExtractLimits,
+		},
+		{
+			name: "no limits",
+		},
+		{
+			name:    "too many entries",
+			limits:  ExtractLimits{Entries: 2},
+			wantErr: true,
+			errMsg:  "chart archive has too many entries: mychart/",
+		},
+		{
+			name:    "file too large",
+			limits:  ExtractLimits{FileSize: 13},
+			wantErr: true,
+			errMsg:  "chart archive file is too large: mychart/templates/deploy.yaml is 16 bytes, the limit is 13 bytes",
+		},
+		{
+			name:    "archive too large",
+			limits:  ExtractLimits{TotalSize: 30},
+			wantErr: true,
+			errMsg:  "chart archive is too large: reached",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := extractTarGz(bytes.NewReader(archive), t.TempDir(), extractOptions{limits: tt.limits})
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExtractTar_Normalize(t *testing.T) {
+	entries := []testTarEntry{
+		{name: "chart/Chart.yaml", typeflag: tar.TypeReg, content: "name: chart"},
+		{name: "chart/templates/", typeflag: tar.TypeDir},
+		{name: "chart/templates/deploy.yaml", typeflag: tar.TypeReg, content: "kind: Deployment"},
+		{name: "chart/scripts/run.sh", typeflag: tar.TypeReg, content: "#!/bin/sh", mode: 0o777},
+	}
+
+	tests := []struct {
+		name      string
+		normalize bool
+		wantMode  os.FileMode
+	}{
+		{name: "archive modes", wantMode: 0o755},
+		{name: "normalized modes", normalize: true, wantMode: normalizedFileMode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := t.TempDir()
+
+			err := extractTar(createTestTar(t, entries), dst, extractOptions{normalize: tt.normalize})
+			require.NoError(t, err)
+
+			fi, err := os.Stat(filepath.Join(dst, "scripts", "run.sh"))
+			require.NoError(t, err)
+			// The umask of the test may clear group and other write bits of archive modes.
+			require.Equal(t, tt.wantMode, fi.Mode().Perm()&^0o022)
+
+			if !tt.normalize {
+				return
+			}
+
+			err = filepath.WalkDir(dst, func(p string, d os.DirEntry, err error) error {
+				require.NoError(t, err)
+
+				fi, err := d.Info()
+				require.NoError(t, err)
+
+				want := normalizedFileMode
+				if d.IsDir() {
+					want = normalizedDirMode
+				}
+
+				require.Equal(t, want, fi.Mode().Perm(), p)
+				require.True(t, normalizedModTime.Equal(fi.ModTime()), p)
+
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExtractTar_Truncate(t *testing.T) {
+	dst := t.TempDir()
+
+	entries := []testTarEntry{{name: "chart/values.yaml", typeflag: tar.TypeReg, content: "replicas: 10\nimage: nginx"}}
+	require.NoError(t, extractTar(createTestTar(t, entries), dst, extractOptions{}))
+
+	entries[0].content = "replicas: 1"
+	require.NoError(t, extractTar(createTestTar(t, entries), dst, extractOptions{}))
+
+	content, err := os.ReadFile(filepath.Join(dst, "values.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "replicas: 1", string(content))
+}
+
+func TestExtractTarGz_Layout(t *testing.T) {
+	archive := createTestTarGz(t, "mychart", map[string]string{
+		"Chart.yaml":                   "name: mychart",
+		"crds/crd.yaml":                "kind: CustomResourceDefinition",
+		"templates/deploy.yaml":        "kind: Deployment",
+		"charts/sub/Chart.yaml":        "name: sub",
+		"charts/sub/templates/cm.yaml": "kind: ConfigMap",
+		"charts/other-1.0.0.tgz":       "archive",
+	})
+
+	tests := []struct {
+		name    string
+		errMsg  string
+		vc      config.VendorChart
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "keep the top-level directory",
+			vc:   config.VendorChart{KeepTopLevel: true},
+			want: []string{
+				"mychart/Chart.yaml", "mychart/charts/other-1.0.0.tgz", "mychart/charts/sub/Chart.yaml",
+				"mychart/charts/sub/templates/cm.yaml", "mychart/crds/crd.yaml", "mychart/templates/deploy.yaml",
+			},
+		},
+		{
+			name: "directory sub-path",
+			vc:   config.VendorChart{SubPath: "crds/"},
+			want: []string{"crd.yaml"},
+		},
+		{
+			name: "nested sub-path",
+			vc:   config.VendorChart{SubPath: "charts/sub"},
+			want: []string{"Chart.yaml", "templates/cm.yaml"},
+		},
+		{
+			name: "file sub-path",
+			vc:   config.VendorChart{SubPath: "charts/other-1.0.0.tgz"},
+			want: []string{"other-1.0.0.tgz"},
+		},
+		{
+			name:    "missing sub-path",
+			vc:      config.VendorChart{SubPath: "charts/missing"},
+			wantErr: true,
+			errMsg:  "sub-path is not in the chart archive: charts/missing",
+		},
+		{
+			name:    "sub-path outside of the chart",
+			vc:      config.VendorChart{SubPath: "../other"},
+			wantErr: true,
+			errMsg:  "sub-path must be a relative path inside the chart: ../other",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := t.TempDir()
+
+			opts, err := newExtractOptions(&tt.vc, ExtractLimits{})
+			if err == nil {
+				err = extractTarGz(bytes.NewReader(archive), dst, opts)
+			}
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, listFiles(t, dst))
+		})
+	}
+}
@@ -2,106 +2,364 @@
 package helm
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"math"
 	"os"
 	"path"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
 	"golang.org/x/sync/errgroup"
+	"helm.sh/helm/v4/pkg/chart/loader/archive"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/chart/v2/loader"
 	"helm.sh/helm/v4/pkg/downloader"
 	"helm.sh/helm/v4/pkg/getter"
+	"helm.sh/helm/v4/pkg/provenance"
 	"helm.sh/helm/v4/pkg/registry"
 	repo "helm.sh/helm/v4/pkg/repo/v1"
 )
 
+var (
+	errChartMismatch       = errors.New("downloaded chart does not match the configuration")
+	errUnsupportedChartAPI = errors.New("unsupported chart apiVersion")
+)
+
+// Result describes the outcome of vendoring a single chart.
+type Result struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	URL     string `json:"url,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+	// RepackedDigest is the digest of the archive written to the destination, when it was repacked.
+	RepackedDigest string `json:"repackedDigest,omitempty"`
+	SignedBy       string `json:"signedBy,omitempty"`
+	// Files holds the digest of every file of an extracted chart, see config.LockedChart.
+	Files       map[string]string `json:"-"`
+	Destination string            `json:"destination,omitempty"`
+	Duration    string            `json:"duration"`
+	Error       string            `json:"error,omitempty"`
+
+	Dependencies []config.LockedDependency `json:"dependencies,omitempty"`
+}
+
+// FetchOptions control how FetchCharts vendors the charts.
+type FetchOptions struct {
+	// Limits bound the content of the chart archives.
+	Limits ExtractLimits
+	// Version is the version of the plugin, recorded in marker files.
+	Version string
+	// Lock is the lock file of the last download, its destinations are known to be vendored.
+	Lock *config.Lock
+	// Force allows overwriting destinations that were not vendored before, see checkDestination,
+	// and discarding the local modifications of extracted charts, see checkLocalChanges.
+	Force bool
+	// SavePatches saves the local modifications of extracted charts as patches before they are overwritten.
+	SavePatches bool
+}
+
 // FetchCharts downloads a list of VendorChart to it's location
 // from it's Helm Repository or OCI Registry,
-// it uses the system's repository cache and configuration.
+// it uses the system's repository cache, configuration and downloader plugins.
 // Repository authentication must be a separate step, this function
 // already assumes you are authenticated to the given registry or repo.
 //
-// Returns an error if any.
-func FetchCharts(s *Settings, vendorCharts []config.VendorChart) error {
-	// Use getter.Getters() instead of getter.All() to avoid cli.EnvSettings dependency
-	// This provides HTTP and OCI getters without pulling in Kubernetes client libraries
-	getters := getter.Getters()
+// Returns a Result for every chart, in the order of vendorCharts, and an error if any chart failed.
+func FetchCharts(s *Settings, vendorCharts []config.VendorChart, opts FetchOptions) ([]Result, error) {
+	getters := newGetters(s)
+
+	// Helm's chart loader has size limits of its own, which would reject charts the given limits allow.
+	// They are package globals, restored once the charts are fetched so other callers keep Helm's defaults.
+	defer func(chartSize, fileSize int64) {
+		archive.MaxDecompressedChartSize, archive.MaxDecompressedFileSize = chartSize, fileSize
+	}(archive.MaxDecompressedChartSize, archive.MaxDecompressedFileSize)
+
+	archive.MaxDecompressedChartSize = orUnlimited(opts.Limits.TotalSize)
+	archive.MaxDecompressedFileSize = orUnlimited(opts.Limits.FileSize)
 
 	rc, cErr := registry.NewClient(registry.ClientOptCredentialsFile(s.RegistryConfig))
 	if cErr != nil {
-		return fmt.Errorf("cannot create new OCI registry client: %w", cErr)
+		return nil, fmt.Errorf("cannot create new OCI registry client: %w", cErr)
 	}
 
 	var eg errgroup.Group
 
+	results := make([]Result, len(vendorCharts))
+
 	for i := range vendorCharts {
 		eg.Go(func() error {
-			logger := slog.With("name", vendorCharts[i].Name)
-			logger.Info("downloading chart", "repo", vendorCharts[i].Repository, "destination", vendorCharts[i].Destination)
+			start := time.Now()
+			results[i] = Result{Name: vendorCharts[i].Name, Version: vendorCharts[i].Version}
 
-			err := os.MkdirAll(vendorCharts[i].Destination, 0o750)
-			if err != nil {
-				return fmt.Errorf("unable to create target directory: %w", err)
-			}
+			err := fetchChart(s, getters, rc, &vendorCharts[i], &results[i], opts)
 
-			dl := downloader.ChartDownloader{
-				Out:              os.Stdout,
-				Getters:          getters,
-				Verify:           getVerify(&vendorCharts[i]),
-				RepositoryConfig: s.RepositoryConfig,
-				RepositoryCache:  s.RepositoryCache,
-				ContentCache:     s.ContentCache,
-				RegistryClient:   rc,
-			}
+			results[i].Duration = time.Since(start).Round(time.Millisecond).String()
 
-			url, err := getChartURL(getters, &vendorCharts[i])
 			if err != nil {
-				return fmt.Errorf("failed to get chart full URL: %w", err)
+				results[i].Error = err.Error()
 			}
 
-			p, v, err := dl.DownloadToCache(url, vendorCharts[i].Version)
-			if err != nil {
-				return fmt.Errorf("unable to download chart: %w", err)
-			}
+			return err
+		})
+	}
+
+	if wErr := eg.Wait(); wErr != nil {
+		return results, fmt.Errorf("unable to download charts: %w", wErr)
+	}
+
+	return results, nil
+}
+
+// fetchChart downloads a single chart to the repository cache, or packages it from its local directory
+// or git repository, then copies or extracts it to its destination.
+// The resolved URL, git commit, digest and destination are recorded in res as soon as they are known.
+func fetchChart(
+	s *Settings, getters getter.Providers, rc *registry.Client, vc *config.VendorChart, res *Result, fo FetchOptions,
+) error {
+	logger := slog.With("name", vc.Name)
+	logger.Info("downloading chart", "repo", vc.Repository, "destination", vc.Destination)
+
+	var (
+		p   string
+		v   *provenance.Verification
+		err error
+	)
+
+	lp, local := vc.LocalPath()
+	_, git := vc.GitURL()
+
+	if local || git {
+		tmpDir, tErr := os.MkdirTemp("", "helm-vendor-package-")
+		if tErr != nil {
+			return fmt.Errorf("unable to create packaging directory: %w", tErr)
+		}
+
+		defer func() {
+			_ = os.RemoveAll(tmpDir)
+		}()
+
+		if git {
+			res.URL = vc.Repository
+			p, res.Commit, err = packageGitChart(vc, tmpDir)
+		} else {
+			p, res.URL, err = packageLocalChart(vc, lp, tmpDir)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		logger.Info("chart packaged", "url", res.URL, "commit", res.Commit)
+	} else {
+		p, v, err = downloadChart(s, getters, rc, vc, res)
+		if err != nil {
+			return err
+		}
+	}
+
+	res.Digest, err = fileDigest(p)
+	if err != nil {
+		return err
+	}
+
+	ch, err := loader.LoadFile(p)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid chart: %w", res.URL, err)
+	}
+
+	res.Version = ch.Metadata.Version
+
+	err = validateChart(vc, ch, res.URL)
+	if err != nil {
+		return err
+	}
+
+	td := config.NewTemplateData(vc, ch.Metadata.AppVersion)
+	// The configured version may be a constraint, paths use the version it resolved to.
+	td.Version = ch.Metadata.Version
+
+	dest, err := vc.RenderDestination(td)
+	if err != nil {
+		return err
+	}
+
+	// Charts keeping their top-level directory may share a destination, they only own the directory named after them.
+	res.Destination = vc.ChartDir(dest)
+
+	if !vc.Extract {
+		fn, fErr := vc.RenderFilename(td)
+		if fErr != nil {
+			return fErr
+		}
+
+		res.Destination = path.Join(dest, fn)
+	}
+
+	err = checkDestination(res.Destination, vc.Name, vc.Extract, fo)
+	if err != nil {
+		return err
+	}
+
+	if vc.Extract {
+		err = checkLocalChanges(s, getters, rc, vc, res.Destination, fo)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.MkdirAll(dest, 0o750)
+	if err != nil {
+		return fmt.Errorf("unable to create target directory: %w", err)
+	}
+
+	if vc.Extract {
+		logger.Info("extracting chart", "destination", dest)
 
-			logger.Info("chart downloaded to cache", "url", url)
+		var opts extractOptions
 
-			if vendorCharts[i].Extract {
-				logger.Info("extracting chart", "destination", vendorCharts[i].Destination)
+		opts, err = newExtractOptions(vc, fo.Limits)
+		if err != nil {
+			return err
+		}
 
-				err = extractChartTgz(p, vendorCharts[i].Destination)
-			} else {
-				destPath := path.Join(
-					vendorCharts[i].Destination,
-					vendorCharts[i].Name+"-"+vendorCharts[i].Version+".tgz",
-				)
+		err = extractChartTgz(p, dest, opts)
+	} else {
+		destPath := res.Destination
 
-				logger.Info("copying chart archive", "destination", destPath)
+		if vc.Repack {
+			logger.Info("repacking chart archive", "destination", destPath)
 
-				err = copyChart(p, destPath)
+			err = repackChart(p, destPath, fo.Limits)
+			if err == nil {
+				res.RepackedDigest, err = fileDigest(destPath)
 			}
+		} else {
+			logger.Info("copying chart archive", "destination", destPath)
+
+			err = copyChart(p, destPath)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to perform chart filemsystem action: %w", err)
+	}
+
+	if vc.Extract && (vc.Dependencies == config.DependenciesBuild || vc.Dependencies == config.DependenciesUpdate) {
+		res.Dependencies, err = vendorDependencies(s, getters, rc, vc, res.Destination)
+		if err != nil {
+			return err
+		}
 
+		if vc.Normalize {
+			err = normalizeTree(path.Join(res.Destination, "charts"))
 			if err != nil {
-				return fmt.Errorf("unable to perform chart filemsystem action: %w", err)
+				return err
 			}
+		}
+	}
 
-			if v != nil && v.SignedBy != nil {
-				slog.Info("chart validated", "url", url, "hash", v.FileHash)
-			}
+	if vc.Extract {
+		res.Files, err = hashTree(res.Destination)
+		if err != nil {
+			return err
+		}
+	}
 
-			return nil
-		})
+	if v != nil && v.SignedBy != nil {
+		res.SignedBy = signer(v)
+		slog.Info("chart validated", "url", res.URL, "hash", v.FileHash, "signer", res.SignedBy)
 	}
 
-	if wErr := eg.Wait(); wErr != nil {
-		return fmt.Errorf("unable to download charts: %w", wErr)
+	if vc.Marker {
+		err = writeMarker(vc, res, fo.Version)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// signer returns the identities of the key the chart's provenance was signed with, sorted and comma separated.
+func signer(v *provenance.Verification) string {
+	return strings.Join(slices.Sorted(maps.Keys(v.SignedBy.Identities)), ", ")
+}
+
+// writeMarker writes the marker file of the vendored chart, see config.MarkerPath.
+func writeMarker(vc *config.VendorChart, res *Result, version string) error {
+	m := config.Marker{
+		Name:           res.Name,
+		Version:        res.Version,
+		Repository:     vc.Repository,
+		URL:            res.URL,
+		Commit:         res.Commit,
+		Digest:         res.Digest,
+		RepackedDigest: res.RepackedDigest,
+		SignedBy:       res.SignedBy,
+		VendoredBy:     "helm-vendor-plugin " + version,
+	}
+
+	p := config.MarkerPath(res.Destination, vc.Extract)
+
+	err := m.Write(p)
+	if err != nil {
+		return err
+	}
+
+	if vc.Normalize {
+		return normalizeTree(p)
+	}
+
+	return nil
+}
+
+// downloadChart downloads a chart from its remote repository to the repository cache, recording its URL in res.
+// Returns the path of the cached archive, its verification or an error if any.
+func downloadChart(
+	s *Settings, getters getter.Providers, rc *registry.Client, vc *config.VendorChart, res *Result,
+) (string, *provenance.Verification, error) {
+	dl := downloader.ChartDownloader{
+		Out:              os.Stderr,
+		Getters:          getters,
+		Verify:           getVerify(vc),
+		RepositoryConfig: s.RepositoryConfig,
+		RepositoryCache:  s.RepositoryCache,
+		ContentCache:     s.ContentCache,
+		RegistryClient:   rc,
+		Options:          []getter.Option{getter.WithInsecureSkipVerifyTLS(vc.Insecure)},
+	}
+
+	err := checkScheme(getters, vc.Repository)
+	if err != nil {
+		return "", nil, err
+	}
+
+	url, err := getChartURL(getters, vc)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get chart full URL: %w", err)
+	}
+
+	res.URL = url
+
+	p, v, err := dl.DownloadToCache(url, vc.Version)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to download chart: %w", err)
+	}
+
+	slog.Info("chart downloaded to cache", "name", vc.Name, "url", url)
+
+	return p, v, nil
+}
+
 // getChartURL returns the full URL for the chart.
 // For OCI repositories this is just Repository + Name,
+// direct archive URLs are used as-is,
 // for Helm Repos it tries to find it in the registry index.
 //
 // Returns the full URL or an error if any.
@@ -110,6 +368,10 @@ func getChartURL(getters getter.Providers, vc *config.VendorChart) (string, erro
 		return vc.Repository + "/" + vc.Name, nil
 	}
 
+	if vc.IsArchive() {
+		return vc.Repository, nil
+	}
+
 	url, err := repo.FindChartInRepoURL(
 		vc.Repository,
 		vc.Name,
@@ -124,6 +386,35 @@ func getChartURL(getters getter.Providers, vc *config.VendorChart) (string, erro
 	return url, nil
 }
 
+// validateChart checks that the chart downloaded from url is the configured chart with a supported apiVersion,
+// since a misconfigured repository or mirror, or a direct archive URL without an index, could serve another one.
+func validateChart(vc *config.VendorChart, ch *chart.Chart, url string) error {
+	if ch.Name() != vc.Name {
+		return fmt.Errorf("%w: %s contains chart %s, not %s", errChartMismatch, url, ch.Name(), vc.Name)
+	}
+
+	if !slices.Contains([]string{chart.APIVersionV1, chart.APIVersionV2}, ch.Metadata.APIVersion) {
+		return fmt.Errorf("%w: %s has apiVersion %q, expected %s or %s",
+			errUnsupportedChartAPI, url, ch.Metadata.APIVersion, chart.APIVersionV1, chart.APIVersionV2)
+	}
+
+	_, err := matchVersion(ch.Metadata.Version, vc.Version)
+	if err != nil {
+		return fmt.Errorf("%s: %w", url, err)
+	}
+
+	return nil
+}
+
+// orUnlimited returns the size limit, or the largest possible size when the limit is disabled.
+func orUnlimited(limit int64) int64 {
+	if limit <= 0 {
+		return math.MaxInt64
+	}
+
+	return limit
+}
+
 // getVerify returns the verification status based on the VendorChart settings
 //
 // We must use the 2 extremes Always and Never cause that's what helms doing too.
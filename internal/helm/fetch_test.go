@@ -1,12 +1,18 @@
 package helm
 
 import (
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v4/pkg/chart/loader/archive"
 	"helm.sh/helm/v4/pkg/downloader"
 	"helm.sh/helm/v4/pkg/getter"
 )
@@ -85,11 +91,21 @@ func TestGetChartURL(t *testing.T) {
 			want:    "oci://docker.io/myrepo/mychart",
 			wantErr: false,
 		},
+		{
+			name: "direct archive URL",
+			vc: &config.VendorChart{
+				Name:       "mychart",
+				Repository: "https://example.com/downloads/mychart-2.0.0.tgz?token=abc",
+				Version:    "2.0.0",
+			},
+			want:    "https://example.com/downloads/mychart-2.0.0.tgz?token=abc",
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// For OCI URLs, getters are not used (function returns early)
+			// For OCI and archive URLs, getters are not used (function returns early)
 			// Pass nil to avoid any potential network calls or dependencies
 			got, err := getChartURL(nil, tt.vc)
 
@@ -216,3 +232,315 @@ entries:
 		})
 	}
 }
+
+func TestFetchCharts_ArchiveURL(t *testing.T) {
+	archive := createTestTarGz(t, "mychart", map[string]string{
+		"Chart.yaml":         "apiVersion: v2\nname: mychart\nversion: 1.2.3\n",
+		"templates/svc.yaml": "kind: Service\n",
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/downloads/mychart-1.2.3.tgz" {
+			w.Header().Set("Content-Type", "application/gzip")
+			_, _ = w.Write(archive)
+
+			return
+		}
+
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	archiveURL := server.URL + "/downloads/mychart-1.2.3.tgz"
+
+	tests := []struct {
+		vc      config.VendorChart
+		name    string
+		errMsg  string
+		wantErr bool
+	}{
+		{
+			name: "matching chart",
+			vc:   config.VendorChart{Name: "mychart", Repository: archiveURL, Version: "1.2.3", Extract: true},
+		},
+		{
+			name:    "name mismatch",
+			vc:      config.VendorChart{Name: "other", Repository: archiveURL, Version: "1.2.3", Extract: true},
+			wantErr: true,
+			errMsg:  "contains chart mychart, not other",
+		},
+		{
+			name:    "version mismatch",
+			vc:      config.VendorChart{Name: "mychart", Repository: archiveURL, Version: "1.0.0", Extract: true},
+			wantErr: true,
+			errMsg:  "chart has version 1.2.3, not 1.0.0",
+		},
+		{
+			name:    "missing archive",
+			vc:      config.VendorChart{Name: "mychart", Repository: server.URL + "/missing-1.0.0.tgz", Version: "1.0.0"},
+			wantErr: true,
+			errMsg:  "unable to download chart",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "vendor")
+			tt.vc.Destination = dest
+
+			results, err := FetchCharts(&Settings{ContentCache: t.TempDir()}, []config.VendorChart{tt.vc}, FetchOptions{})
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, archiveURL, results[0].URL)
+			require.FileExists(t, filepath.Join(dest, "templates", "svc.yaml"))
+		})
+	}
+}
+
+func TestFetchCharts_Validation(t *testing.T) {
+	archives := map[string][]byte{
+		"mychart-1.0.0.tgz": createTestTarGz(t, "mychart", map[string]string{
+			"Chart.yaml": "apiVersion: v2\nname: mychart\nversion: 1.0.0\n",
+		}),
+		"mirrored-1.0.0.tgz": createTestTarGz(t, "other", map[string]string{
+			"Chart.yaml": "apiVersion: v2\nname: other\nversion: 1.0.0\n",
+		}),
+		"stale-2.0.0.tgz": createTestTarGz(t, "stale", map[string]string{
+			"Chart.yaml": "apiVersion: v2\nname: stale\nversion: 1.0.0\n",
+		}),
+		"future-1.0.0.tgz": createTestTarGz(t, "future", map[string]string{
+			"Chart.yaml": "apiVersion: v9\nname: future\nversion: 1.0.0\n",
+		}),
+		"broken-1.0.0.tgz": createTestTarGz(t, "broken", map[string]string{
+			"values.yaml": "replicas: 1\n",
+		}),
+	}
+
+	index := "apiVersion: v1\nentries:\n"
+	for _, e := range []struct{ name, version, file string }{
+		{"mychart", "1.0.0", "mychart-1.0.0.tgz"},
+		{"mirrored", "1.0.0", "mirrored-1.0.0.tgz"},
+		{"stale", "2.0.0", "stale-2.0.0.tgz"},
+		{"future", "1.0.0", "future-1.0.0.tgz"},
+		{"broken", "1.0.0", "broken-1.0.0.tgz"},
+	} {
+		index += "  " + e.name + ":\n    - name: " + e.name + "\n      version: " + e.version + "\n      urls:\n        - charts/" + e.file + "\n"
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/index.yaml" {
+			_, _ = w.Write([]byte(index))
+
+			return
+		}
+
+		if a, ok := archives[strings.TrimPrefix(r.URL.Path, "/charts/")]; ok {
+			w.Header().Set("Content-Type", "application/gzip")
+			_, _ = w.Write(a)
+
+			return
+		}
+
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		chart   string
+		version string
+		errMsg  string
+		wantErr bool
+	}{
+		{
+			name:    "matching chart",
+			chart:   "mychart",
+			version: "1.0.0",
+		},
+		{
+			name:    "another chart",
+			chart:   "mirrored",
+			version: "1.0.0",
+			wantErr: true,
+			errMsg:  "downloaded chart does not match the configuration: " + server.URL + "/charts/mirrored-1.0.0.tgz contains chart other, not mirrored",
+		},
+		{
+			name:    "another version",
+			chart:   "stale",
+			version: "2.0.0",
+			wantErr: true,
+			errMsg:  "version mismatch: chart has version 1.0.0, not 2.0.0",
+		},
+		{
+			name:    "unsupported apiVersion",
+			chart:   "future",
+			version: "1.0.0",
+			wantErr: true,
+			errMsg:  `unsupported chart apiVersion: ` + server.URL + `/charts/future-1.0.0.tgz has apiVersion "v9", expected v1 or v2`,
+		},
+		{
+			name:    "not a chart",
+			chart:   "broken",
+			version: "1.0.0",
+			wantErr: true,
+			errMsg:  server.URL + "/charts/broken-1.0.0.tgz is not a valid chart",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vc := config.VendorChart{Name: tt.chart, Repository: server.URL, Version: tt.version, Destination: t.TempDir()}
+
+			s := &Settings{
+				ContentCache:     t.TempDir(),
+				RepositoryCache:  t.TempDir(),
+				RepositoryConfig: filepath.Join(t.TempDir(), "repositories.yaml"),
+			}
+
+			_, err := FetchCharts(s, []config.VendorChart{vc}, FetchOptions{})
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestFetchCharts_Marker(t *testing.T) {
+	archive := createTestTarGz(t, "mychart", map[string]string{
+		"Chart.yaml":         "apiVersion: v2\nname: mychart\nversion: 1.2.3\n",
+		"templates/svc.yaml": "kind: Service\n",
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	archiveURL := server.URL + "/mychart-1.2.3.tgz"
+
+	tests := []struct {
+		name       string
+		vc         config.VendorChart
+		markerPath string
+	}{
+		{
+			name:       "extracted chart",
+			vc:         config.VendorChart{Extract: true},
+			markerPath: ".vendor.json",
+		},
+		{
+			name:       "chart archive",
+			vc:         config.VendorChart{Filename: "{{.Name}}-{{.Version}}.tgz", Repack: true},
+			markerPath: "mychart-1.2.3.tgz.vendor.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := t.TempDir()
+
+			tt.vc.Name = "mychart"
+			tt.vc.Repository = archiveURL
+			tt.vc.Version = "1.2.3"
+			tt.vc.Destination = dest
+			tt.vc.Marker = true
+
+			results, err := FetchCharts(&Settings{ContentCache: t.TempDir()}, []config.VendorChart{tt.vc}, FetchOptions{Version: "v1.2.0"})
+			require.NoError(t, err)
+
+			m, err := config.ReadMarker(filepath.Join(dest, tt.markerPath))
+			require.NoError(t, err)
+			require.Equal(t, &config.Marker{
+				Name:           "mychart",
+				Version:        "1.2.3",
+				Repository:     archiveURL,
+				URL:            archiveURL,
+				Digest:         results[0].Digest,
+				RepackedDigest: results[0].RepackedDigest,
+				VendoredBy:     "helm-vendor-plugin v1.2.0",
+			}, m)
+		})
+	}
+}
+
+func TestFetchCharts_RestoresLoaderLimits(t *testing.T) {
+	chartSize, fileSize := archive.MaxDecompressedChartSize, archive.MaxDecompressedFileSize
+
+	_, err := FetchCharts(&Settings{}, nil, FetchOptions{Limits: ExtractLimits{TotalSize: 1, FileSize: 1}})
+	require.NoError(t, err)
+	require.Equal(t, chartSize, archive.MaxDecompressedChartSize)
+	require.Equal(t, fileSize, archive.MaxDecompressedFileSize)
+}
+
+func TestFetchCharts_SharedDestination(t *testing.T) {
+	archives := map[string][]byte{}
+
+	for _, name := range []string{"a", "b"} {
+		archives["/"+name+"-1.0.0.tgz"] = createTestTarGz(t, name, map[string]string{
+			"Chart.yaml":         "apiVersion: v2\nname: " + name + "\nversion: 1.0.0\n",
+			"templates/svc.yaml": "kind: Service\n",
+		})
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write(archives[r.URL.Path])
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "vendor")
+	require.NoError(t, os.MkdirAll(dest, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "README.md"), []byte("# vendored charts\n"), 0o600))
+
+	var vcs []config.VendorChart
+
+	for _, name := range []string{"a", "b"} {
+		vcs = append(vcs, config.VendorChart{
+			Name: name, Repository: server.URL + "/" + name + "-1.0.0.tgz", Version: "1.0.0", Destination: dest,
+			Extract: true, KeepTopLevel: true, Normalize: true, Marker: true,
+		})
+	}
+
+	s := &Settings{ContentCache: t.TempDir()}
+
+	results, err := FetchCharts(s, vcs, FetchOptions{})
+	require.NoError(t, err)
+
+	l := &config.Lock{}
+
+	for i, name := range []string{"a", "b"} {
+		require.Equal(t, filepath.Join(dest, name), results[i].Destination)
+		require.Equal(t, []string{"Chart.yaml", "templates/svc.yaml"}, slices.Sorted(maps.Keys(results[i].Files)))
+		require.FileExists(t, filepath.Join(dest, name, config.MarkerFile))
+
+		l.Charts = append(l.Charts, config.LockedChart{
+			Name: name, Version: "1.0.0", Destination: results[i].Destination, Files: results[i].Files,
+		})
+	}
+
+	// Files of the shared destination are neither normalized nor counted as modifications of the charts.
+	fi, err := os.Stat(filepath.Join(dest, "README.md"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	_, err = FetchCharts(s, vcs, FetchOptions{Lock: l})
+	require.NoError(t, err)
+
+	require.NoError(t, RemoveVendored(&vcs[0], l, false))
+	require.NoDirExists(t, filepath.Join(dest, "a"))
+	require.DirExists(t, filepath.Join(dest, "b"))
+	require.FileExists(t, filepath.Join(dest, "README.md"))
+}
@@ -3,17 +3,230 @@ package helm
 import (
 	"archive/tar"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
 	securejoin "github.com/cyphar/filepath-securejoin"
 )
 
-var errUnknownHeaderType = errors.New("unknown filesystem header")
+var (
+	errUnknownHeaderType     = errors.New("unknown filesystem header")
+	errUnresolvedDestination = errors.New("destination depends on the chart's appVersion, remove the files by hand")
+	errDestinationNotOwned   = errors.New("destination has content that was not vendored, pass --force to overwrite it")
+	errRemoveNotOwned        = errors.New("destination has content that was not vendored, pass --force to delete it")
+	errRemoveNotRecorded     = errors.New("vendored files are not recorded in the lock file, pass --force to delete the whole destination")
+)
+
+// RemoveVendored deletes the files vendored for the chart, as recorded in the lock l: the files of extracted charts,
+// along with the directories they leave empty, or the archive of packaged ones, along with its directory when it
+// becomes empty. Marker files are deleted too. Destinations that were not vendored, or extracted charts whose files are not recorded, are only
+// deleted with force, the whole destination directory then.
+func RemoveVendored(vc *config.VendorChart, l *config.Lock, force bool) error {
+	dest, locked, err := vendoredPath(vc, l)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(dest); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if !force && locked == nil && !hasMarker(dest, vc.Name, vc.Extract) {
+		return fmt.Errorf("%w: %s", errRemoveNotOwned, dest)
+	}
+
+	if !vc.Extract {
+		for _, p := range []string{dest, config.MarkerPath(dest, false)} {
+			err = os.Remove(p)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("unable to remove chart archive or its marker file: %w", err)
+			}
+		}
+
+		entries, err := os.ReadDir(filepath.Dir(dest))
+		if err == nil && len(entries) == 0 {
+			_ = os.Remove(filepath.Dir(dest))
+		}
+
+		return nil
+	}
+
+	if locked != nil && len(locked.Files) > 0 {
+		return removeFiles(dest, locked.Files)
+	}
+
+	if !force {
+		return fmt.Errorf("%w: %s", errRemoveNotRecorded, dest)
+	}
+
+	err = os.RemoveAll(dest)
+	if err != nil {
+		return fmt.Errorf("unable to remove extracted chart: %w", err)
+	}
+
+	return nil
+}
+
+// vendoredPath returns the destination directory of the extracted chart, or the path of its archive, along with
+// its lock entry, nil if it is not locked. The configured version may be a constraint, so the paths are rendered
+// with the locked versions of the chart first.
+func vendoredPath(vc *config.VendorChart, l *config.Lock) (string, *config.LockedChart, error) {
+	appVersion := strings.Contains(vc.Destination+vc.Filename, ".AppVersion")
+
+	var named []*config.LockedChart
+
+	for i := range l.Charts {
+		if l.Charts[i].Name != vc.Name {
+			continue
+		}
+
+		named = append(named, &l.Charts[i])
+
+		if appVersion {
+			continue
+		}
+
+		td := config.NewTemplateData(vc, "")
+		td.Version = l.Charts[i].Version
+
+		p, err := renderVendoredPath(vc, td)
+		if err == nil && filepath.Clean(p) == filepath.Clean(l.Charts[i].Destination) {
+			return l.Charts[i].Destination, &l.Charts[i], nil
+		}
+	}
+
+	if appVersion {
+		// The appVersion is not locked, but a single entry of the chart can only be this one.
+		if len(named) == 1 {
+			return named[0].Destination, named[0], nil
+		}
+
+		return "", nil, fmt.Errorf("%w: %s", errUnresolvedDestination, vc.Destination)
+	}
+
+	p, err := renderVendoredPath(vc, config.NewTemplateData(vc, ""))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return p, nil, nil
+}
+
+// renderVendoredPath renders the destination directory of the extracted chart, or the path of its archive.
+func renderVendoredPath(vc *config.VendorChart, td config.TemplateData) (string, error) {
+	dest, err := vc.RenderDestination(td)
+	if err != nil || vc.Extract {
+		return vc.ChartDir(dest), err
+	}
+
+	fn, err := vc.RenderFilename(td)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dest, fn), nil
+}
+
+// removeFiles deletes the files of the extracted chart at dest and its marker file, then the directories left empty.
+// Files added by hand are kept, along with their directories.
+func removeFiles(dest string, files map[string]string) error {
+	err := os.Remove(config.MarkerPath(dest, true))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("unable to remove marker file: %w", err)
+	}
+
+	for f := range files {
+		p, err := securejoin.SecureJoin(dest, f)
+		if err != nil {
+			return fmt.Errorf("path contains invalid segments: %w", err)
+		}
+
+		err = os.Remove(p)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("unable to remove vendored file: %w", err)
+		}
+	}
+
+	var dirs []string
+
+	err = filepath.WalkDir(dest, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, p)
+		}
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to read extracted chart: %w", err)
+	}
+
+	// Children come after their parents in walk order, remove them first. Directories with files left fail.
+	for _, d := range slices.Backward(dirs) {
+		_ = os.Remove(d)
+	}
+
+	if _, err := os.Stat(dest); err == nil {
+		slog.Warn("destination has files that were not vendored, they were kept", "destination", dest)
+	}
+
+	return nil
+}
+
+// hasMarker reports whether the destination has a marker file of the named chart, see config.MarkerPath.
+// A marker of another chart doesn't count, the destination was vendored for that one.
+func hasMarker(dest, name string, extract bool) bool {
+	m, err := config.ReadMarker(config.MarkerPath(dest, extract))
+
+	return err == nil && m.Name == name
+}
+
+// checkDestination refuses to write into an existing destination that was not vendored before, so a typo in a
+// destination can't clobber hand maintained files. The destination is the directory of extracted charts, it is
+// only protected when it is not empty, or the archive of packaged ones.
+// Destinations recorded in the lock file or having a marker file of the named chart were vendored,
+// every destination is writable with Force.
+func checkDestination(dest, name string, extract bool, fo FetchOptions) error {
+	if fo.Force {
+		return nil
+	}
+
+	fi, err := os.Stat(dest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to read destination: %w", err)
+	}
+
+	if extract && fi.IsDir() {
+		entries, rErr := os.ReadDir(dest)
+		if rErr != nil {
+			return fmt.Errorf("unable to read destination: %w", rErr)
+		}
+
+		if len(entries) == 0 {
+			return nil
+		}
+	}
+
+	if lockedChart(fo.Lock, dest) != nil || hasMarker(dest, name, extract) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", errDestinationNotOwned, dest)
+}
 
 // copyChart copies the chart archive to the destination directory.
 func copyChart(srcPath, dstPath string) error {
@@ -43,14 +256,35 @@ func copyChart(srcPath, dstPath string) error {
 	return nil
 }
 
+// fileDigest returns the sha256 digest of the file in the `sha256:<hex>` form.
+func fileDigest(p string) (string, error) {
+	f, err := os.Open(filepath.Clean(p))
+	if err != nil {
+		return "", fmt.Errorf("cannot open chart in repository cache: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", fmt.Errorf("cannot compute chart digest: %w", err)
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // extractChartTgz decompress the source gzip archive, then copy the files from the tar archive to the destination.
-func extractChartTgz(src, dst string) error {
+func extractChartTgz(src, dst string, opts extractOptions) error {
 	f, err := os.Open(filepath.Clean(src))
 	if err != nil {
 		return fmt.Errorf("cannot open chart in repository cache: %w", err)
 	}
 
-	err = extractTarGz(f, dst)
+	err = extractTarGz(f, dst, opts)
 	if err != nil {
 		return fmt.Errorf("extracting tgz: %w", err)
 	}
@@ -59,7 +293,7 @@ func extractChartTgz(src, dst string) error {
 }
 
 // extractTarGz extracts a gzipped tar archive to a directory.
-func extractTarGz(r io.Reader, dst string) error {
+func extractTarGz(r io.Reader, dst string, opts extractOptions) error {
 	gzr, err := gzip.NewReader(r)
 	if err != nil {
 		return fmt.Errorf("unable to read gzip: %w", err)
@@ -69,13 +303,27 @@ func extractTarGz(r io.Reader, dst string) error {
 		_ = gzr.Close()
 	}()
 
-	return extractTar(gzr, dst)
+	return extractTar(gzr, dst, opts)
 }
 
-// extractTar extracts a tar archive to a directory.
-func extractTar(r io.Reader, dst string) error {
+// extractTar extracts a tar archive to a directory, skipping the entries filtered out by the options.
+// The top-level directory of the archive is stripped, unless the options keep it or only extract a sub-path.
+// Symlinks and hardlinks are created once every other entry is extracted, see createLinks.
+// Extraction stops with an error at the first entry exceeding the limits of the options.
+// With the normalize option every file and directory gets the same modes and modification time, see normalizeTree.
+func extractTar(r io.Reader, dst string, opts extractOptions) error {
 	tarReader := tar.NewReader(r)
 
+	var (
+		links []archiveLink
+		// matched reports whether any entry is extracted, which only fails to happen with a sub-path.
+		matched bool
+		// roots holds the top-level directories extracted when they are kept.
+		roots = map[string]bool{}
+	)
+
+	usage := archiveUsage{limits: opts.limits}
+
 	for {
 		header, hErr := tarReader.Next()
 		if errors.Is(hErr, io.EOF) {
@@ -86,15 +334,38 @@ func extractTar(r io.Reader, dst string) error {
 			return fmt.Errorf("unable to read tar content: %w", hErr)
 		}
 
-		// Strip the top-level directory from the path
+		// Skipped entries count too, they are decompressed all the same.
+		if err := usage.add(header); err != nil {
+			return err
+		}
+
+		// Rules match the path inside the chart, without its top-level directory.
 		parts := strings.SplitN(header.Name, "/", 2)
 		if len(parts) < 2 {
 			continue
 		}
 
-		p, pErr := securejoin.SecureJoin(dst, parts[1])
+		rel, ok := opts.destPath(header.Name, header.Typeflag == tar.TypeDir)
+		if !ok {
+			continue
+		}
+
+		matched = true
+
+		if opts.keepTopLevel {
+			roots[parts[0]] = true
+		}
+
+		skip := opts.filter.skip(parts[1], header.FileInfo())
+		opts.filter.track(parts[1], header.FileInfo(), !skip)
+
+		if skip {
+			continue
+		}
+
+		p, pErr := entryPath(dst, rel)
 		if pErr != nil {
-			return fmt.Errorf("path contains invalid segments: %w", pErr)
+			return pErr
 		}
 
 		switch header.Typeflag {
@@ -108,8 +379,19 @@ func extractTar(r io.Reader, dst string) error {
 				return fmt.Errorf("create parent folders for file: %w", err)
 			}
 
+			// Don't write through a link left by a previous extraction.
+			if err := removeLink(p); err != nil {
+				return err
+			}
+
 			//nolint:gosec // G115 is a false alert, since it's all file modes it's safe
-			outFile, err := os.OpenFile(filepath.Clean(p), os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
+			mode := os.FileMode(header.Mode)
+			if opts.normalize {
+				mode = normalizedFileMode
+			}
+
+			// Truncate existing files, a shorter new version would keep the end of the old one otherwise.
+			outFile, err := os.OpenFile(filepath.Clean(p), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
 			if err != nil {
 				return fmt.Errorf("read file content: %w", err)
 			}
@@ -121,6 +403,13 @@ func extractTar(r io.Reader, dst string) error {
 			}
 
 			_ = outFile.Close()
+		case tar.TypeSymlink, tar.TypeLink:
+			l, err := newArchiveLink(dst, p, header, opts)
+			if err != nil {
+				return err
+			}
+
+			links = append(links, l)
 		case tar.TypeXGlobalHeader, tar.TypeXHeader:
 			continue
 		default:
@@ -128,5 +417,42 @@ func extractTar(r io.Reader, dst string) error {
 		}
 	}
 
-	return nil
+	if !matched && opts.subPath != "" {
+		return fmt.Errorf("%w: %s", errSubPathNotFound, opts.subPath)
+	}
+
+	// A sub-path isn't meant to be a whole chart.
+	if opts.subPath == "" {
+		for _, p := range opts.filter.filteredOut() {
+			slog.Warn("include or exclude rules filter out a path the chart needs", "path", p)
+		}
+	}
+
+	err := createLinks(dst, links, opts.dereference)
+	if err != nil {
+		return err
+	}
+
+	if !opts.normalize {
+		return nil
+	}
+
+	// Destinations of charts keeping their top-level directory may hold other charts, which are left alone.
+	if opts.keepTopLevel {
+		for root := range roots {
+			p, err := entryPath(dst, root)
+			if err != nil {
+				return err
+			}
+
+			err = normalizeTree(p)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return normalizeTree(dst)
 }
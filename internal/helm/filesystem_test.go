@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
 	"github.com/stretchr/testify/require"
 )
 
@@ -225,7 +226,7 @@ func TestExtractChartTgz(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			srcPath, dstPath := tt.setup(t)
 
-			err := extractChartTgz(srcPath, dstPath)
+			err := extractChartTgz(srcPath, dstPath, extractOptions{})
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -299,7 +300,7 @@ func TestExtractTarGz(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			reader, dstPath := tt.setup(t)
 
-			err := extractTarGz(reader, dstPath)
+			err := extractTarGz(reader, dstPath, extractOptions{})
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -470,7 +471,7 @@ func TestExtractTar(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			reader, dstPath := tt.setup(t)
 
-			err := extractTar(reader, dstPath)
+			err := extractTar(reader, dstPath, extractOptions{})
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -487,3 +488,360 @@ func TestExtractTar(t *testing.T) {
 		})
 	}
 }
+
+func TestFileDigest(t *testing.T) {
+	tmpDir := t.TempDir()
+	p := filepath.Join(tmpDir, "chart.tgz")
+
+	err := os.WriteFile(p, []byte("chart"), 0o644)
+	require.NoError(t, err)
+
+	got, err := fileDigest(p)
+	require.NoError(t, err)
+	require.Equal(t, "sha256:cc57fc1903e444cf6a726490b43b27ee9f87facc037f86872201847c565b45fb", got)
+
+	_, err = fileDigest(filepath.Join(tmpDir, "missing.tgz"))
+	require.Error(t, err)
+}
+
+func TestCheckDestination(t *testing.T) {
+	tests := []struct {
+		setup   func(t *testing.T, dir string) (dest string, fo FetchOptions)
+		name    string
+		extract bool
+		wantErr bool
+	}{
+		{
+			name:    "missing destination",
+			extract: true,
+			setup: func(t *testing.T, dir string) (string, FetchOptions) {
+				t.Helper()
+
+				return filepath.Join(dir, "vendor"), FetchOptions{}
+			},
+		},
+		{
+			name:    "empty destination",
+			extract: true,
+			setup: func(t *testing.T, dir string) (string, FetchOptions) {
+				t.Helper()
+
+				return dir, FetchOptions{}
+			},
+		},
+		{
+			name:    "hand maintained files",
+			extract: true,
+			wantErr: true,
+			setup: func(t *testing.T, dir string) (string, FetchOptions) {
+				t.Helper()
+
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("replicas: 1"), 0o600))
+
+				return dir, FetchOptions{}
+			},
+		},
+		{
+			name:    "forced",
+			extract: true,
+			setup: func(t *testing.T, dir string) (string, FetchOptions) {
+				t.Helper()
+
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("replicas: 1"), 0o600))
+
+				return dir, FetchOptions{Force: true}
+			},
+		},
+		{
+			name:    "locked destination",
+			extract: true,
+			setup: func(t *testing.T, dir string) (string, FetchOptions) {
+				t.Helper()
+
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: mychart"), 0o600))
+
+				return dir, FetchOptions{Lock: &config.Lock{Charts: []config.LockedChart{{Name: "mychart", Destination: dir + "/"}}}}
+			},
+		},
+		{
+			name:    "marked destination",
+			extract: true,
+			setup: func(t *testing.T, dir string) (string, FetchOptions) {
+				t.Helper()
+
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: mychart"), 0o600))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, config.MarkerFile), []byte(`{"name": "mychart"}`), 0o600))
+
+				return dir, FetchOptions{}
+			},
+		},
+		{
+			name:    "destination marked for another chart",
+			extract: true,
+			wantErr: true,
+			setup: func(t *testing.T, dir string) (string, FetchOptions) {
+				t.Helper()
+
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: other"), 0o600))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, config.MarkerFile), []byte(`{"name": "other"}`), 0o600))
+
+				return dir, FetchOptions{}
+			},
+		},
+		{
+			name: "new archive in a shared directory",
+			setup: func(t *testing.T, dir string) (string, FetchOptions) {
+				t.Helper()
+
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "other-1.0.0.tgz"), []byte("archive"), 0o600))
+
+				return filepath.Join(dir, "mychart-1.0.0.tgz"), FetchOptions{}
+			},
+		},
+		{
+			name:    "existing archive",
+			wantErr: true,
+			setup: func(t *testing.T, dir string) (string, FetchOptions) {
+				t.Helper()
+
+				p := filepath.Join(dir, "mychart-1.0.0.tgz")
+				require.NoError(t, os.WriteFile(p, []byte("archive"), 0o600))
+
+				return p, FetchOptions{}
+			},
+		},
+		{
+			name: "marked archive",
+			setup: func(t *testing.T, dir string) (string, FetchOptions) {
+				t.Helper()
+
+				p := filepath.Join(dir, "mychart-1.0.0.tgz")
+				require.NoError(t, os.WriteFile(p, []byte("archive"), 0o600))
+				require.NoError(t, os.WriteFile(config.MarkerPath(p, false), []byte(`{"name": "mychart"}`), 0o600))
+
+				return p, FetchOptions{}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest, fo := tt.setup(t, t.TempDir())
+
+			err := checkDestination(dest, "mychart", tt.extract, fo)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errDestinationNotOwned)
+				require.Contains(t, err.Error(), dest)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRemoveVendored(t *testing.T) {
+	tests := []struct {
+		setup   func(t *testing.T, dir string) (vc config.VendorChart, l *config.Lock)
+		verify  func(t *testing.T, dir string)
+		name    string
+		force   bool
+		wantErr error
+	}{
+		{
+			name: "locked files of an extracted chart",
+			setup: func(t *testing.T, dir string) (config.VendorChart, *config.Lock) {
+				t.Helper()
+
+				dest := filepath.Join(dir, "app")
+				require.NoError(t, os.MkdirAll(filepath.Join(dest, "templates"), 0o750))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, "Chart.yaml"), []byte("name: app"), 0o600))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, "templates", "svc.yaml"), []byte("kind: Service"), 0o600))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, "handwritten.yaml"), []byte("kind: ConfigMap"), 0o600))
+
+				vc := config.VendorChart{Name: "app", Version: "^1.0.0", Destination: dest, Extract: true}
+				l := &config.Lock{Charts: []config.LockedChart{{
+					Name: "app", Version: "1.0.0", Destination: dest,
+					Files: map[string]string{"Chart.yaml": "sha256:1", "templates/svc.yaml": "sha256:2"},
+				}}}
+
+				return vc, l
+			},
+			verify: func(t *testing.T, dir string) {
+				t.Helper()
+
+				require.FileExists(t, filepath.Join(dir, "app", "handwritten.yaml"))
+				require.NoFileExists(t, filepath.Join(dir, "app", "Chart.yaml"))
+				require.NoDirExists(t, filepath.Join(dir, "app", "templates"))
+			},
+		},
+		{
+			name: "locked files and marker of an extracted chart",
+			setup: func(t *testing.T, dir string) (config.VendorChart, *config.Lock) {
+				t.Helper()
+
+				dest := filepath.Join(dir, "app")
+				require.NoError(t, os.MkdirAll(dest, 0o750))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, "Chart.yaml"), []byte("name: app"), 0o600))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, config.MarkerFile), []byte(`{"name": "app"}`), 0o600))
+
+				vc := config.VendorChart{Name: "app", Version: "1.0.0", Destination: dest, Extract: true, Marker: true}
+				l := &config.Lock{Charts: []config.LockedChart{
+					{Name: "app", Version: "1.0.0", Destination: dest, Files: map[string]string{"Chart.yaml": "sha256:1"}},
+				}}
+
+				return vc, l
+			},
+			verify: func(t *testing.T, dir string) {
+				t.Helper()
+
+				require.NoDirExists(t, filepath.Join(dir, "app"))
+			},
+		},
+		{
+			name:    "extracted chart marked for another chart",
+			wantErr: errRemoveNotOwned,
+			setup: func(t *testing.T, dir string) (config.VendorChart, *config.Lock) {
+				t.Helper()
+
+				dest := filepath.Join(dir, "app")
+				require.NoError(t, os.MkdirAll(dest, 0o750))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, config.MarkerFile), []byte(`{"name": "other"}`), 0o600))
+
+				return config.VendorChart{Name: "app", Version: "1.0.0", Destination: dest, Extract: true}, &config.Lock{}
+			},
+			verify: func(t *testing.T, dir string) {
+				t.Helper()
+
+				require.FileExists(t, filepath.Join(dir, "app", config.MarkerFile))
+			},
+		},
+		{
+			name:    "extracted chart that was not vendored",
+			wantErr: errRemoveNotOwned,
+			setup: func(t *testing.T, dir string) (config.VendorChart, *config.Lock) {
+				t.Helper()
+
+				dest := filepath.Join(dir, "app")
+				require.NoError(t, os.MkdirAll(dest, 0o750))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, "handwritten.yaml"), []byte("kind: ConfigMap"), 0o600))
+
+				return config.VendorChart{Name: "app", Version: "1.0.0", Destination: dest, Extract: true}, &config.Lock{}
+			},
+			verify: func(t *testing.T, dir string) {
+				t.Helper()
+
+				require.FileExists(t, filepath.Join(dir, "app", "handwritten.yaml"))
+			},
+		},
+		{
+			name:    "extracted chart without recorded files",
+			wantErr: errRemoveNotRecorded,
+			setup: func(t *testing.T, dir string) (config.VendorChart, *config.Lock) {
+				t.Helper()
+
+				dest := filepath.Join(dir, "app")
+				require.NoError(t, os.MkdirAll(dest, 0o750))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, "Chart.yaml"), []byte("name: app"), 0o600))
+
+				vc := config.VendorChart{Name: "app", Version: "1.0.0", Destination: dest, Extract: true}
+
+				return vc, &config.Lock{Charts: []config.LockedChart{{Name: "app", Version: "1.0.0", Destination: dest}}}
+			},
+			verify: func(t *testing.T, dir string) {
+				t.Helper()
+
+				require.FileExists(t, filepath.Join(dir, "app", "Chart.yaml"))
+			},
+		},
+		{
+			name:  "forced",
+			force: true,
+			setup: func(t *testing.T, dir string) (config.VendorChart, *config.Lock) {
+				t.Helper()
+
+				dest := filepath.Join(dir, "app")
+				require.NoError(t, os.MkdirAll(dest, 0o750))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, "handwritten.yaml"), []byte("kind: ConfigMap"), 0o600))
+
+				return config.VendorChart{Name: "app", Version: "1.0.0", Destination: dest, Extract: true}, &config.Lock{}
+			},
+			verify: func(t *testing.T, dir string) {
+				t.Helper()
+
+				require.NoDirExists(t, filepath.Join(dir, "app"))
+			},
+		},
+		{
+			name: "locked archive",
+			setup: func(t *testing.T, dir string) (config.VendorChart, *config.Lock) {
+				t.Helper()
+
+				dest := filepath.Join(dir, "charts")
+				require.NoError(t, os.MkdirAll(dest, 0o750))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, "app-1.2.0.tgz"), []byte("archive"), 0o600))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, "app-1.2.0.tgz"+config.MarkerFile), []byte(`{"name": "app"}`), 0o600))
+
+				vc := config.VendorChart{Name: "app", Version: "~1.2.0", Destination: dest}
+				l := &config.Lock{Charts: []config.LockedChart{
+					{Name: "app", Version: "1.2.0", Destination: filepath.Join(dest, "app-1.2.0.tgz")},
+				}}
+
+				return vc, l
+			},
+			verify: func(t *testing.T, dir string) {
+				t.Helper()
+
+				require.NoDirExists(t, filepath.Join(dir, "charts"))
+			},
+		},
+		{
+			name:    "archive that was not vendored",
+			wantErr: errRemoveNotOwned,
+			setup: func(t *testing.T, dir string) (config.VendorChart, *config.Lock) {
+				t.Helper()
+
+				dest := filepath.Join(dir, "charts")
+				require.NoError(t, os.MkdirAll(dest, 0o750))
+				require.NoError(t, os.WriteFile(filepath.Join(dest, "app-1.2.0.tgz"), []byte("archive"), 0o600))
+
+				return config.VendorChart{Name: "app", Version: "1.2.0", Destination: dest}, &config.Lock{}
+			},
+			verify: func(t *testing.T, dir string) {
+				t.Helper()
+
+				require.FileExists(t, filepath.Join(dir, "charts", "app-1.2.0.tgz"))
+			},
+		},
+		{
+			name: "missing destination",
+			setup: func(t *testing.T, dir string) (config.VendorChart, *config.Lock) {
+				t.Helper()
+
+				return config.VendorChart{Name: "app", Version: "1.0.0", Destination: filepath.Join(dir, "app"), Extract: true}, &config.Lock{}
+			},
+			verify: func(t *testing.T, dir string) {
+				t.Helper()
+
+				require.NoDirExists(t, filepath.Join(dir, "app"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			vc, l := tt.setup(t, dir)
+
+			err := RemoveVendored(&vc, l, tt.force)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			tt.verify(t, dir)
+		})
+	}
+}
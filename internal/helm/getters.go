@@ -0,0 +1,38 @@
+package helm
+
+import (
+	"fmt"
+	"net/url"
+
+	"helm.sh/helm/v4/pkg/cli"
+	"helm.sh/helm/v4/pkg/getter"
+)
+
+// newGetters returns the built-in HTTP and OCI getters, along with the getters of the downloader plugins
+// (like helm-s3 or helm-gcs) installed in the Helm plugins directory, if it is known.
+// Plugins are run with the HELM_* environment Helm passed to this plugin.
+func newGetters(s *Settings) getter.Providers {
+	if s.PluginsDirectory == "" {
+		return getter.Getters()
+	}
+
+	env := cli.New()
+	env.PluginsDirectory = s.PluginsDirectory
+
+	return getter.All(env)
+}
+
+// checkScheme reports a readable error when no getter, built-in or plugin, handles the scheme of the repository.
+func checkScheme(getters getter.Providers, repository string) error {
+	u, err := url.Parse(repository)
+	if err != nil {
+		return fmt.Errorf("invalid repository URL: %w", err)
+	}
+
+	_, err = getters.ByScheme(u.Scheme)
+	if err != nil {
+		return fmt.Errorf("no getter supports the %q scheme, install a Helm downloader plugin for it: %w", u.Scheme, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestGetterPlugin installs a legacy downloader plugin for the `fake://` scheme in pluginsDir,
+// serving the files of root: `fake://charts/x` is read from `root/x`.
+func createTestGetterPlugin(t *testing.T, pluginsDir, root string) {
+	t.Helper()
+
+	dir := filepath.Join(pluginsDir, "fake-getter")
+	require.NoError(t, os.MkdirAll(dir, 0o750))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plugin.yaml"), []byte(`name: fake-getter
+version: 0.1.0
+downloaders:
+  - command: get.sh
+    protocols:
+      - fake
+`), 0o600))
+
+	//nolint:gosec // The plugin command must be executable.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "get.sh"), []byte(`#!/bin/sh
+exec cat "`+root+`/${4#fake://charts/}"
+`), 0o700))
+}
+
+func TestFetchCharts_GetterPlugin(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "bucket")
+	pluginsDir := filepath.Join(tmpDir, "plugins")
+
+	require.NoError(t, os.MkdirAll(root, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "index.yaml"), []byte(`apiVersion: v1
+entries:
+  mychart:
+    - name: mychart
+      version: 1.0.0
+      urls:
+        - mychart-1.0.0.tgz
+`), 0o600))
+
+	archive := createTestTarGz(t, "mychart", map[string]string{
+		"Chart.yaml": "apiVersion: v2\nname: mychart\nversion: 1.0.0\n",
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(root, "mychart-1.0.0.tgz"), archive, 0o600))
+
+	createTestGetterPlugin(t, pluginsDir, root)
+
+	tests := []struct {
+		settings *Settings
+		name     string
+		errMsg   string
+		wantErr  bool
+	}{
+		{
+			name:     "plugin getter",
+			settings: &Settings{ContentCache: t.TempDir(), PluginsDirectory: pluginsDir},
+		},
+		{
+			name:     "no plugins directory",
+			settings: &Settings{ContentCache: t.TempDir()},
+			wantErr:  true,
+			errMsg:   `no getter supports the "fake" scheme`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "vendor")
+			vc := config.VendorChart{
+				Name: "mychart", Repository: "fake://charts", Version: "1.0.0", Destination: dest,
+				Filename: config.DefaultFilename,
+			}
+
+			results, err := FetchCharts(tt.settings, []config.VendorChart{vc}, FetchOptions{})
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, "fake://charts/mychart-1.0.0.tgz", results[0].URL)
+
+			got, err := os.ReadFile(filepath.Join(dest, "mychart-1.0.0.tgz"))
+			require.NoError(t, err)
+			require.Equal(t, archive, got)
+		})
+	}
+}
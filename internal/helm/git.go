@@ -0,0 +1,135 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	securejoin "github.com/cyphar/filepath-securejoin"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+)
+
+var errGitCommand = errors.New("git command failed")
+
+// defaultGitRef is checked out when a git chart has no ref, the default branch of the remote.
+const defaultGitRef = "HEAD"
+
+// runGit runs a git command in dir, without ever prompting for credentials.
+// Returns the trimmed standard output or an error including git's standard error.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...) //nolint:gosec // Arguments are passed to git directly, not through a shell.
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	out, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			return "", fmt.Errorf("%w: git %s: %s", errGitCommand, args[0], strings.TrimSpace(string(ee.Stderr)))
+		}
+
+		return "", fmt.Errorf("unable to run git: %w", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// checkoutGitRef fetches the ref of the remote repository at url into a new repository in dir and checks it out.
+// Branches, tags and full commit SHAs are fetched shallowly, anything else (e.g. an abbreviated SHA)
+// falls back to fetching every ref of the remote.
+// Returns the SHA of the checked out commit or an error if any.
+func checkoutGitRef(dir, url, ref string, insecure bool) (string, error) {
+	var opts []string
+	if insecure {
+		opts = append(opts, "-c", "http.sslVerify=false")
+	}
+
+	_, err := runGit(dir, "init", "--quiet")
+	if err != nil {
+		return "", err
+	}
+
+	rev := "FETCH_HEAD"
+
+	_, err = runGit(dir, append(opts, "fetch", "--quiet", "--depth", "1", "--", url, ref)...)
+	if err != nil {
+		slog.Debug("shallow fetch failed, fetching every ref", "url", url, "ref", ref, "error", err)
+
+		_, err = runGit(dir, append(opts, "fetch", "--quiet", "--update-head-ok", "--", url, "+refs/*:refs/*")...)
+		if err != nil {
+			return "", err
+		}
+
+		rev = ref
+	}
+
+	sha, err := runGit(dir, "rev-parse", "--verify", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve ref %s: %w", ref, err)
+	}
+
+	_, err = runGit(dir, "checkout", "--quiet", "--detach", sha)
+	if err != nil {
+		return "", err
+	}
+
+	return sha, nil
+}
+
+// loadGitChart checks out the chart's ref of its git repository into tmpDir, then loads the chart
+// found at its path, or at the repository root.
+// Returns the chart, its directory, the commit SHA or an error if any.
+func loadGitChart(vc *config.VendorChart, tmpDir string) (*chart.Chart, string, string, error) {
+	url, _ := vc.GitURL()
+
+	ref := vc.Ref
+	if ref == "" {
+		ref = defaultGitRef
+	}
+
+	repoDir := filepath.Join(tmpDir, "repo")
+
+	err := os.Mkdir(repoDir, 0o750)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("unable to create git directory: %w", err)
+	}
+
+	sha, err := checkoutGitRef(repoDir, url, ref, vc.Insecure)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("unable to check out %s at %s: %w", url, ref, err)
+	}
+
+	chartDir, err := securejoin.SecureJoin(repoDir, vc.Path)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("path contains invalid segments: %w", err)
+	}
+
+	ch, dir, err := loadLocalChart(chartDir, vc.Name)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return ch, dir, sha, nil
+}
+
+// packageGitChart packages the chart of a git repository into a tgz archive in tmpDir,
+// see loadGitChart and packageChart.
+// Returns the archive path, the commit SHA or an error if any.
+func packageGitChart(vc *config.VendorChart, tmpDir string) (string, string, error) {
+	ch, dir, sha, err := loadGitChart(vc, tmpDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	p, err := packageChart(vc, ch, dir, tmpDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	return p, sha, nil
+}
@@ -0,0 +1,160 @@
+package helm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestGitRepository creates a bare git repository with one commit per entry of commits,
+// each writing the given files, and tags every commit with its version.
+// Returns the path of the bare repository and the SHA of every commit.
+func createTestGitRepository(t *testing.T, commits []map[string]string, tags []string) (string, []string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tmpDir := t.TempDir()
+	work := filepath.Join(tmpDir, "work")
+	bare := filepath.Join(tmpDir, "charts.git")
+
+	git := func(dir string, args ...string) string {
+		t.Helper()
+
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+
+		out, err := runGit(dir, args...)
+		require.NoError(t, err)
+
+		return out
+	}
+
+	require.NoError(t, os.MkdirAll(work, 0o750))
+	git(work, "init", "--quiet", "--initial-branch", "main")
+
+	shas := make([]string, 0, len(commits))
+
+	for i, files := range commits {
+		for name, content := range files {
+			p := filepath.Join(work, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o750))
+			require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+		}
+
+		git(work, "add", "--all")
+		git(work, "commit", "--quiet", "--message", tags[i])
+		git(work, "tag", tags[i])
+
+		shas = append(shas, git(work, "rev-parse", "HEAD"))
+	}
+
+	git(tmpDir, "clone", "--quiet", "--bare", work, bare)
+
+	return bare, shas
+}
+
+func TestFetchCharts_GitRepository(t *testing.T) {
+	chartYAML := func(version string) string {
+		return "apiVersion: v2\nname: app\nversion: " + version + "\n"
+	}
+
+	bare, shas := createTestGitRepository(t, []map[string]string{
+		{
+			"charts/app/Chart.yaml":        chartYAML("1.0.0"),
+			"charts/app/templates/v1.yaml": "kind: ConfigMap\n",
+			"README.md":                    "# charts\n",
+		},
+		{
+			"charts/app/Chart.yaml":        chartYAML("1.1.0"),
+			"charts/app/templates/v2.yaml": "kind: Secret\n",
+		},
+	}, []string{"v1.0.0", "v1.1.0"})
+
+	repository := "git+file://" + bare
+
+	tests := []struct {
+		vc         config.VendorChart
+		name       string
+		wantCommit string
+		wantFile   string
+		errMsg     string
+		wantErr    bool
+	}{
+		{
+			name:       "default branch",
+			vc:         config.VendorChart{Name: "app", Repository: repository, Version: "1.1.0", Path: "charts/app"},
+			wantCommit: shas[1],
+			wantFile:   "templates/v2.yaml",
+		},
+		{
+			name:       "tag",
+			vc:         config.VendorChart{Name: "app", Repository: repository, Version: "1.0.0", Ref: "v1.0.0", Path: "charts"},
+			wantCommit: shas[0],
+			wantFile:   "templates/v1.yaml",
+		},
+		{
+			name:       "branch",
+			vc:         config.VendorChart{Name: "app", Repository: repository, Version: "1.1.0", Ref: "main", Path: "charts/app"},
+			wantCommit: shas[1],
+			wantFile:   "templates/v2.yaml",
+		},
+		{
+			name:       "abbreviated commit",
+			vc:         config.VendorChart{Name: "app", Repository: repository, Version: "1.0.0", Ref: shas[0][:10], Path: "charts/app"},
+			wantCommit: shas[0],
+			wantFile:   "templates/v1.yaml",
+		},
+		{
+			name:    "version mismatch",
+			vc:      config.VendorChart{Name: "app", Repository: repository, Version: "1.0.0", Ref: "v1.1.0", Path: "charts/app"},
+			wantErr: true,
+			errMsg:  "chart has version 1.1.0, not 1.0.0",
+		},
+		{
+			name:    "unknown ref",
+			vc:      config.VendorChart{Name: "app", Repository: repository, Version: "1.0.0", Ref: "v9.9.9", Path: "charts/app"},
+			wantErr: true,
+			errMsg:  "unable to resolve ref v9.9.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "vendor")
+			tt.vc.Destination = dest
+			tt.vc.Extract = true
+
+			results, err := FetchCharts(&Settings{}, []config.VendorChart{tt.vc}, FetchOptions{})
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantCommit, results[0].Commit)
+			require.Equal(t, repository, results[0].URL)
+			require.FileExists(t, filepath.Join(dest, "Chart.yaml"))
+			require.FileExists(t, filepath.Join(dest, tt.wantFile))
+			require.NoFileExists(t, filepath.Join(dest, "README.md"))
+		})
+	}
+}
+
+func TestResolveChartVersion_GitRepository(t *testing.T) {
+	bare, _ := createTestGitRepository(t, []map[string]string{
+		{"Chart.yaml": "apiVersion: v2\nname: app\nversion: 2.3.0\n"},
+	}, []string{"v2.3.0"})
+
+	got, err := ResolveChartVersion(&Settings{}, &config.VendorChart{Name: "app", Repository: "git+file://" + bare})
+	require.NoError(t, err)
+	require.Equal(t, "2.3.0", got)
+}
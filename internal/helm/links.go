@@ -0,0 +1,372 @@
+package helm
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+)
+
+// maxLinkDepth limits how many symlinks are followed while resolving or dereferencing a single link.
+const maxLinkDepth = 16
+
+var (
+	errLinkEscape   = errors.New("link points outside of the destination")
+	errLinkTarget   = errors.New("link target does not exist")
+	errLinkTooDeep  = errors.New("too many levels of symbolic links")
+	errLinkLoop     = errors.New("link points to a directory containing it")
+	errLinkNotFile  = errors.New("link target is not a regular file or directory")
+	errLinkHardlink = errors.New("hardlink target is not in the chart")
+)
+
+// archiveLink is a symlink or hardlink entry of a chart archive, links are created after every other entry
+// was extracted, since they may point to entries that come later in the archive.
+type archiveLink struct {
+	// name is the name of the archive entry.
+	name string
+	// path is where the link is created.
+	path string
+	// linkname is the target of a symlink as written in the archive.
+	linkname string
+	// target is the destination relative, slash separated path the link points to.
+	target string
+	hard   bool
+}
+
+// entryPath returns the path of the chart relative archive entry name inside dst.
+// Symlinks in the parent directories are resolved inside dst, the last element is not, so that
+// an existing link can be replaced instead of writing through it.
+func entryPath(dst, name string) (string, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return dst, nil
+	}
+
+	dir, base := path.Split(name)
+
+	parent, err := securejoin.SecureJoin(dst, dir)
+	if err != nil {
+		return "", fmt.Errorf("path contains invalid segments: %w", err)
+	}
+
+	return filepath.Join(parent, base), nil
+}
+
+// newArchiveLink validates a symlink or hardlink header extracted to p, rejecting links that point outside of dst.
+// Symlink targets are relative to the link, hardlink targets are archive entry names, mapped like the entries are.
+func newArchiveLink(dst, p string, header *tar.Header, opts extractOptions) (archiveLink, error) {
+	l := archiveLink{name: header.Name, path: p, linkname: header.Linkname, hard: header.Typeflag == tar.TypeLink}
+
+	if l.hard {
+		target, ok := opts.destPath(header.Linkname, false)
+		if !ok || target == "" {
+			return archiveLink{}, fmt.Errorf("%w: %s links to %s", errLinkHardlink, header.Name, header.Linkname)
+		}
+
+		l.target = path.Clean(target)
+	} else {
+		if path.IsAbs(header.Linkname) || filepath.IsAbs(header.Linkname) {
+			return archiveLink{}, fmt.Errorf("%w: %s links to %s", errLinkEscape, header.Name, header.Linkname)
+		}
+
+		rel, err := filepath.Rel(dst, filepath.Join(filepath.Dir(p), filepath.FromSlash(header.Linkname)))
+		if err != nil {
+			return archiveLink{}, fmt.Errorf("%w: %s links to %s", errLinkEscape, header.Name, header.Linkname)
+		}
+
+		l.target = filepath.ToSlash(rel)
+	}
+
+	if l.target == ".." || strings.HasPrefix(l.target, "../") || path.IsAbs(l.target) {
+		return archiveLink{}, fmt.Errorf("%w: %s links to %s", errLinkEscape, header.Name, header.Linkname)
+	}
+
+	return l, nil
+}
+
+// createLinks creates the links of the archive in dst, or copies of their targets when dereference is set.
+// Links are created first in both cases, so dereferenced links can point to other links.
+// Symlinks are resolved once they all exist, since a chain of them can leave dst even when every target
+// stays inside it on its own, see resolveInside.
+func createLinks(dst string, links []archiveLink, dereference bool) error {
+	for _, l := range links {
+		err := removeFile(l.path)
+		if err != nil {
+			return err
+		}
+
+		err = os.MkdirAll(filepath.Dir(l.path), 0o750)
+		if err != nil {
+			return fmt.Errorf("create parent folders for link: %w", err)
+		}
+
+		if !l.hard {
+			err = os.Symlink(filepath.FromSlash(l.linkname), l.path)
+			if err != nil {
+				return fmt.Errorf("create symlink %s: %w", l.name, err)
+			}
+
+			continue
+		}
+
+		target, err := securejoin.SecureJoin(dst, l.target)
+		if err != nil {
+			return fmt.Errorf("path contains invalid segments: %w", err)
+		}
+
+		err = os.Link(target, l.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s links to %s", errLinkTarget, l.name, l.linkname)
+		}
+
+		if err != nil {
+			return fmt.Errorf("create hardlink %s: %w", l.name, err)
+		}
+	}
+
+	for _, l := range links {
+		if l.hard {
+			continue
+		}
+
+		err := resolveInside(dst, l.path)
+		if err != nil {
+			_ = os.Remove(l.path)
+
+			return fmt.Errorf("%w: %s links to %s", err, l.name, l.linkname)
+		}
+	}
+
+	if !dereference {
+		return nil
+	}
+
+	for _, l := range links {
+		err := dereferenceLink(dst, l)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveInside follows the path p inside dst the way the kernel would, symlink by symlink, and fails when any step
+// leaves dst. Unlike securejoin, which keeps the path inside dst by clamping it, this reports the escape.
+// Missing path elements are resolved lexically.
+func resolveInside(dst, p string) error {
+	rel, err := filepath.Rel(dst, p)
+	if err != nil {
+		return errLinkEscape
+	}
+
+	var (
+		resolved  []string
+		remaining = strings.Split(filepath.ToSlash(rel), "/")
+		depth     int
+	)
+
+	for len(remaining) > 0 {
+		elem := remaining[0]
+		remaining = remaining[1:]
+
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return errLinkEscape
+			}
+
+			resolved = resolved[:len(resolved)-1]
+
+			continue
+		}
+
+		cur := filepath.Join(dst, filepath.FromSlash(path.Join(append(resolved, elem)...)))
+
+		fi, err := os.Lstat(cur)
+		if err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+			resolved = append(resolved, elem)
+
+			continue
+		}
+
+		depth++
+		if depth > maxLinkDepth {
+			return errLinkTooDeep
+		}
+
+		target, err := os.Readlink(cur)
+		if err != nil {
+			return fmt.Errorf("read link: %w", err)
+		}
+
+		if filepath.IsAbs(target) {
+			return errLinkEscape
+		}
+
+		remaining = append(strings.Split(filepath.ToSlash(target), "/"), remaining...)
+	}
+
+	return nil
+}
+
+// dereferenceLink replaces the link with a regular copy of the file or directory it points to,
+// hardlinks are copied too so they stop sharing their content.
+func dereferenceLink(dst string, l archiveLink) error {
+	target, err := securejoin.SecureJoin(dst, l.target)
+	if err != nil {
+		return fmt.Errorf("path contains invalid segments: %w", err)
+	}
+
+	fi, err := os.Stat(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s links to %s", errLinkTarget, l.name, l.linkname)
+	}
+
+	if err != nil {
+		return fmt.Errorf("read link target: %w", err)
+	}
+
+	err = os.Remove(l.path)
+	if err != nil {
+		return fmt.Errorf("remove link %s: %w", l.name, err)
+	}
+
+	err = copyLinkTarget(dst, target, l.path, fi, 0)
+	if err != nil {
+		return fmt.Errorf("dereference %s: %w", l.name, err)
+	}
+
+	return nil
+}
+
+// copyLinkTarget copies the file or directory src to dstPath, following the symlinks inside dst it finds on the way.
+func copyLinkTarget(dst, src, dstPath string, fi fs.FileInfo, depth int) error {
+	if depth > maxLinkDepth {
+		return fmt.Errorf("%w: %s", errLinkTooDeep, src)
+	}
+
+	if fi.Mode().IsRegular() {
+		return copyFile(src, dstPath, fi.Mode().Perm())
+	}
+
+	if !fi.IsDir() {
+		return fmt.Errorf("%w: %s", errLinkNotFile, src)
+	}
+
+	// Copying a directory into itself would never end.
+	rel, err := filepath.Rel(src, dstPath)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%w: %s", errLinkLoop, src)
+	}
+
+	err = os.MkdirAll(dstPath, 0o750)
+	if err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("read directory: %w", err)
+	}
+
+	for _, e := range entries {
+		p := filepath.Join(src, e.Name())
+		d := depth
+
+		// Resolve nested links inside dst, they were validated when they were created.
+		if e.Type()&fs.ModeSymlink != 0 {
+			rel, rErr := filepath.Rel(dst, p)
+			if rErr != nil {
+				return fmt.Errorf("%w: %s", errLinkEscape, p)
+			}
+
+			p, rErr = securejoin.SecureJoin(dst, rel)
+			if rErr != nil {
+				return fmt.Errorf("path contains invalid segments: %w", rErr)
+			}
+
+			d++
+		}
+
+		efi, sErr := os.Stat(p)
+		if sErr != nil {
+			return fmt.Errorf("%w: %s", errLinkTarget, filepath.Join(src, e.Name()))
+		}
+
+		err = copyLinkTarget(dst, p, filepath.Join(dstPath, e.Name()), efi, d)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies the regular file src to dst with the given permissions.
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return fmt.Errorf("open link target: %w", err)
+	}
+
+	defer func() {
+		_ = in.Close()
+	}()
+
+	out, err := os.OpenFile(filepath.Clean(dst), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		_ = out.Close()
+		return fmt.Errorf("cannot copy file: %w", err)
+	}
+
+	return out.Close()
+}
+
+// removeFile removes the file or link at p so it can be replaced, directories and missing files are left alone.
+func removeFile(p string) error {
+	fi, err := os.Lstat(p)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && fi.IsDir()) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("read existing file: %w", err)
+	}
+
+	err = os.Remove(p)
+	if err != nil {
+		return fmt.Errorf("remove existing file: %w", err)
+	}
+
+	return nil
+}
+
+// removeLink removes the symlink at p, so the file it is replaced with isn't written through it.
+func removeLink(p string) error {
+	fi, err := os.Lstat(p)
+	if err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+		return nil
+	}
+
+	err = os.Remove(p)
+	if err != nil {
+		return fmt.Errorf("remove existing link: %w", err)
+	}
+
+	return nil
+}
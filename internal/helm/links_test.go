@@ -0,0 +1,227 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testTarEntry is an entry of a tar archive built by createTestTar.
+type testTarEntry struct {
+	name     string
+	content  string
+	linkname string
+	mode     int64
+	typeflag byte
+}
+
+// createTestTar creates an uncompressed tar archive with the given entries, in order.
+func createTestTar(t *testing.T, entries []testTarEntry) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+
+	for _, e := range entries {
+		mode := e.mode
+		if mode == 0 {
+			mode = 0o644
+		}
+
+		header := &tar.Header{
+			Name:     e.name,
+			Linkname: e.linkname,
+			Mode:     mode,
+			Size:     int64(len(e.content)),
+			Typeflag: e.typeflag,
+		}
+
+		require.NoError(t, tw.WriteHeader(header))
+
+		_, err := tw.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+
+	return &buf
+}
+
+func TestExtractTar_Links(t *testing.T) {
+	entries := []testTarEntry{
+		// Links may come before their targets.
+		{name: "chart/values.yaml", typeflag: tar.TypeSymlink, linkname: "ci/default.yaml"},
+		{name: "chart/ci/default.yaml", typeflag: tar.TypeReg, content: "replicas: 1"},
+		{name: "chart/templates/_helpers.tpl", typeflag: tar.TypeLink, linkname: "chart/ci/default.yaml"},
+		{name: "chart/files", typeflag: tar.TypeSymlink, linkname: "ci"},
+		{name: "chart/defaults.yaml", typeflag: tar.TypeSymlink, linkname: "values.yaml"},
+	}
+
+	tests := []struct {
+		name        string
+		dereference bool
+	}{
+		{name: "links"},
+		{name: "dereferenced links", dereference: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := t.TempDir()
+
+			err := extractTar(createTestTar(t, entries), dst, extractOptions{dereference: tt.dereference})
+			require.NoError(t, err)
+
+			for _, p := range []string{"values.yaml", "templates/_helpers.tpl", "files/default.yaml", "defaults.yaml"} {
+				content, rErr := os.ReadFile(filepath.Join(dst, p))
+				require.NoError(t, rErr, p)
+				require.Equal(t, "replicas: 1", string(content), p)
+			}
+
+			fi, err := os.Lstat(filepath.Join(dst, "values.yaml"))
+			require.NoError(t, err)
+			require.Equal(t, !tt.dereference, fi.Mode()&os.ModeSymlink != 0)
+
+			fi, err = os.Lstat(filepath.Join(dst, "files"))
+			require.NoError(t, err)
+			require.Equal(t, tt.dereference, fi.IsDir())
+
+			// Dereferenced hardlinks don't share their content anymore.
+			require.NoError(t, os.WriteFile(filepath.Join(dst, "ci", "default.yaml"), []byte("replicas: 2"), 0o600))
+
+			helpers, err := os.ReadFile(filepath.Join(dst, "templates", "_helpers.tpl"))
+			require.NoError(t, err)
+			require.Equal(t, tt.dereference, string(helpers) == "replicas: 1")
+		})
+	}
+}
+
+func TestExtractTar_LinksAgain(t *testing.T) {
+	dst := t.TempDir()
+
+	entries := []testTarEntry{
+		{name: "chart/values.yaml", typeflag: tar.TypeSymlink, linkname: "ci/default.yaml"},
+		{name: "chart/ci/default.yaml", typeflag: tar.TypeReg, content: "replicas: 1"},
+	}
+
+	require.NoError(t, extractTar(createTestTar(t, entries), dst, extractOptions{}))
+
+	// A link replaced by a file is not written through.
+	entries[0] = testTarEntry{name: "chart/values.yaml", typeflag: tar.TypeReg, content: "replicas: 3"}
+	require.NoError(t, extractTar(createTestTar(t, entries), dst, extractOptions{}))
+
+	content, err := os.ReadFile(filepath.Join(dst, "ci", "default.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "replicas: 1", string(content))
+
+	content, err = os.ReadFile(filepath.Join(dst, "values.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "replicas: 3", string(content))
+}
+
+func TestExtractTar_LinkErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		errMsg  string
+		entries []testTarEntry
+	}{
+		{
+			name:    "absolute symlink",
+			entries: []testTarEntry{{name: "chart/passwd", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}},
+			errMsg:  "link points outside of the destination: chart/passwd links to /etc/passwd",
+		},
+		{
+			name:    "relative symlink escaping the destination",
+			entries: []testTarEntry{{name: "chart/templates/x", typeflag: tar.TypeSymlink, linkname: "../../x"}},
+			errMsg:  "link points outside of the destination: chart/templates/x links to ../../x",
+		},
+		{
+			name: "chained symlinks escaping the destination",
+			entries: []testTarEntry{
+				{name: "chart/sub/", typeflag: tar.TypeDir},
+				{name: "chart/sub/up", typeflag: tar.TypeSymlink, linkname: ".."},
+				{name: "chart/esc", typeflag: tar.TypeSymlink, linkname: "sub/up/.."},
+			},
+			errMsg: "link points outside of the destination: chart/esc links to sub/up/..",
+		},
+		{
+			name: "nested chained symlinks escaping the destination",
+			entries: []testTarEntry{
+				{name: "chart/c/sub/", typeflag: tar.TypeDir},
+				{name: "chart/c/sub/up", typeflag: tar.TypeSymlink, linkname: ".."},
+				{name: "chart/c/esc", typeflag: tar.TypeSymlink, linkname: "sub/up/../.."},
+			},
+			errMsg: "link points outside of the destination: chart/c/esc links to sub/up/../..",
+		},
+		{
+			name:    "symlink loop",
+			entries: []testTarEntry{{name: "chart/x", typeflag: tar.TypeSymlink, linkname: "x"}},
+			errMsg:  "too many levels of symbolic links: chart/x links to x",
+		},
+		{
+			name:    "hardlink escaping the destination",
+			entries: []testTarEntry{{name: "chart/x", typeflag: tar.TypeLink, linkname: "chart/../../x"}},
+			errMsg:  "link points outside of the destination",
+		},
+		{
+			name:    "hardlink outside of the chart",
+			entries: []testTarEntry{{name: "chart/x", typeflag: tar.TypeLink, linkname: "x"}},
+			errMsg:  "hardlink target is not in the chart",
+		},
+		{
+			name:    "missing hardlink target",
+			entries: []testTarEntry{{name: "chart/x", typeflag: tar.TypeLink, linkname: "chart/y"}},
+			errMsg:  "link target does not exist: chart/x links to chart/y",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A file next to the destination, which escaping links must not reach.
+			dir := t.TempDir()
+			dst := filepath.Join(dir, "vendor")
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0o600))
+
+			err := extractTar(createTestTar(t, tt.entries), dst, extractOptions{})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.errMsg)
+			require.NoFileExists(t, filepath.Join(dst, "esc", "secret"))
+		})
+	}
+}
+
+func TestExtractTar_DereferenceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		errMsg  string
+		entries []testTarEntry
+	}{
+		{
+			name:    "dangling symlink",
+			entries: []testTarEntry{{name: "chart/x", typeflag: tar.TypeSymlink, linkname: "y"}},
+			errMsg:  "link target does not exist: chart/x links to y",
+		},
+		{
+			name: "symlink loop",
+			entries: []testTarEntry{
+				{name: "chart/a/", typeflag: tar.TypeDir},
+				{name: "chart/a/loop", typeflag: tar.TypeSymlink, linkname: "."},
+				{name: "chart/b", typeflag: tar.TypeSymlink, linkname: "a"},
+			},
+			errMsg: "dereference chart/a/loop: link points to a directory containing it",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := extractTar(createTestTar(t, tt.entries), t.TempDir(), extractOptions{dereference: true})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/chart/v2/loader"
+	chartutil "helm.sh/helm/v4/pkg/chart/v2/util"
+	"helm.sh/helm/v4/pkg/registry"
+)
+
+var (
+	errLocalChartMismatch = errors.New("local chart does not match the configuration")
+	errVersionMismatch    = errors.New("version mismatch")
+	errPackagedVerify     = errors.New("provenance verification is not supported for local and git charts")
+)
+
+// localChartDir returns the chart directory inside a local repository: the repository itself when it is
+// the chart, or its subdirectory named after the chart otherwise.
+func localChartDir(repository, name string) string {
+	md, err := chartutil.LoadChartfile(filepath.Join(repository, "Chart.yaml"))
+	if err == nil && md.Name == name {
+		return repository
+	}
+
+	return filepath.Join(repository, name)
+}
+
+// loadLocalChart loads the unpacked chart from a local repository and checks that it is the named chart.
+// Returns the chart, its absolute directory or an error if any.
+func loadLocalChart(repository, name string) (*chart.Chart, string, error) {
+	dir, err := filepath.Abs(localChartDir(repository, name))
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to resolve local chart path: %w", err)
+	}
+
+	ch, err := loader.Load(dir)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to load local chart: %w", err)
+	}
+
+	if ch.Name() != name {
+		return nil, "", fmt.Errorf("%w: %s is chart %s, not %s", errLocalChartMismatch, dir, ch.Name(), name)
+	}
+
+	return ch, dir, nil
+}
+
+// packageLocalChart packages the chart of a local repository into a tgz archive in tmpDir, see packageChart.
+// Returns the archive path, the chart's `file://` URL or an error if any.
+func packageLocalChart(vc *config.VendorChart, repository, tmpDir string) (string, string, error) {
+	ch, dir, err := loadLocalChart(repository, vc.Name)
+	if err != nil {
+		return "", "", err
+	}
+
+	p, err := packageChart(vc, ch, dir, tmpDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	return p, "file://" + filepath.ToSlash(dir), nil
+}
+
+// packageChart packages an unpacked chart into a tgz archive in tmpDir, honoring its `.helmignore`.
+// The chart's version must match the configured version or constraint.
+// Returns the archive path or an error if any.
+func packageChart(vc *config.VendorChart, ch *chart.Chart, dir, tmpDir string) (string, error) {
+	if vc.Verify {
+		return "", errPackagedVerify
+	}
+
+	_, err := matchVersion(ch.Metadata.Version, vc.Version)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", dir, err)
+	}
+
+	p, err := chartutil.Save(ch, tmpDir)
+	if err != nil {
+		return "", fmt.Errorf("unable to package local chart: %w", err)
+	}
+
+	return p, nil
+}
+
+// matchVersion checks the version of an unpacked chart against a version or constraint, empty matches any version.
+// Returns the chart's version or an error if it doesn't match.
+func matchVersion(chartVersion, version string) (string, error) {
+	_, err := registry.GetTagMatchingVersionOrConstraint([]string{chartVersion}, version)
+	if err != nil {
+		return "", fmt.Errorf("%w: chart has version %s, not %s", errVersionMismatch, chartVersion, version)
+	}
+
+	return chartVersion, nil
+}
@@ -0,0 +1,116 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchCharts_LocalRepository(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "charts")
+
+	files := map[string]string{
+		"api/Chart.yaml":             "apiVersion: v2\nname: api\nversion: 1.2.0\nappVersion: 2.0.0\n",
+		"api/values.yaml":            "replicas: 1\n",
+		"api/templates/deploy.yaml":  "kind: Deployment\n",
+		"api/ci/test-values.yaml":    "replicas: 3\n",
+		"api/.helmignore":            "ci/\n",
+		"worker/Chart.yaml":          "apiVersion: v2\nname: worker\nversion: 0.1.0\n",
+		"worker/templates/cron.yaml": "kind: CronJob\n",
+	}
+
+	for name, content := range files {
+		p := filepath.Join(repoDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o750))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+	}
+
+	tests := []struct {
+		vc      config.VendorChart
+		verify  func(t *testing.T, dest string)
+		name    string
+		errMsg  string
+		wantErr bool
+	}{
+		{
+			name: "extract chart from a directory of charts",
+			vc:   config.VendorChart{Name: "api", Repository: "file://" + repoDir, Version: "1.2.0", Extract: true},
+			verify: func(t *testing.T, dest string) {
+				t.Helper()
+
+				require.FileExists(t, filepath.Join(dest, "Chart.yaml"))
+				require.FileExists(t, filepath.Join(dest, "templates", "deploy.yaml"))
+				require.NoFileExists(t, filepath.Join(dest, "ci", "test-values.yaml"))
+			},
+		},
+		{
+			name: "package chart directory",
+			vc: config.VendorChart{
+				Name: "worker", Repository: filepath.Join(repoDir, "worker"), Version: "0.1.0",
+				Filename: config.DefaultFilename,
+			},
+			verify: func(t *testing.T, dest string) {
+				t.Helper()
+
+				require.FileExists(t, filepath.Join(dest, "worker-0.1.0.tgz"))
+			},
+		},
+		{
+			name: "package chart matching a version constraint",
+			vc: config.VendorChart{
+				Name: "worker", Repository: filepath.Join(repoDir, "worker"), Version: "^0.1.0",
+				Filename: config.DefaultFilename,
+			},
+			verify: func(t *testing.T, dest string) {
+				t.Helper()
+
+				require.FileExists(t, filepath.Join(dest, "worker-0.1.0.tgz"))
+			},
+		},
+		{
+			name:    "version mismatch",
+			vc:      config.VendorChart{Name: "api", Repository: repoDir, Version: "1.0.0", Extract: true},
+			wantErr: true,
+			errMsg:  "has version 1.2.0, not 1.0.0",
+		},
+		{
+			name:    "missing chart",
+			vc:      config.VendorChart{Name: "web", Repository: repoDir, Version: "1.0.0", Extract: true},
+			wantErr: true,
+			errMsg:  "unable to load local chart",
+		},
+		{
+			name:    "verification is not supported",
+			vc:      config.VendorChart{Name: "api", Repository: repoDir, Version: "1.2.0", Verify: true},
+			wantErr: true,
+			errMsg:  errPackagedVerify.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "vendor")
+			tt.vc.Destination = dest
+
+			results, err := FetchCharts(&Settings{}, []config.VendorChart{tt.vc}, FetchOptions{})
+
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			require.Contains(t, results[0].URL, "file://")
+			require.NotEmpty(t, results[0].Digest)
+
+			tt.verify(t, dest)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// repackEntry is an entry of a chart archive, held in memory until every entry is read so they can be sorted.
+type repackEntry struct {
+	header  *tar.Header
+	content []byte
+}
+
+// repackChart writes the chart archive src to dst deterministically: entries sorted by name, with fixed
+// modification times and owners, compressed with a fixed gzip header, so identical content yields identical bytes.
+// The entries of src are checked against the limits, since they are held in memory.
+func repackChart(src, dst string, limits ExtractLimits) error {
+	f, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return fmt.Errorf("cannot open chart in repository cache: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	entries, err := readRepackEntries(f, limits)
+	if err != nil {
+		return fmt.Errorf("repacking chart: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	err = writeRepackEntries(&buf, entries)
+	if err != nil {
+		return fmt.Errorf("repacking chart: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Clean(dst), buf.Bytes(), 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot create chart in target path: %w", err)
+	}
+
+	return nil
+}
+
+// readRepackEntries reads every entry of the gzipped tar archive, sorted by name.
+func readRepackEntries(r io.Reader, limits ExtractLimits) ([]repackEntry, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read gzip: %w", err)
+	}
+
+	defer func() {
+		_ = gzr.Close()
+	}()
+
+	tr := tar.NewReader(gzr)
+	usage := archiveUsage{limits: limits}
+
+	var entries []repackEntry
+
+	for {
+		header, hErr := tr.Next()
+		if errors.Is(hErr, io.EOF) {
+			break
+		}
+
+		if hErr != nil {
+			return nil, fmt.Errorf("unable to read tar content: %w", hErr)
+		}
+
+		if err := usage.add(header); err != nil {
+			return nil, err
+		}
+
+		// Global headers only carry metadata, like the commit of archives created by git.
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read file: %w", err)
+		}
+
+		entries = append(entries, repackEntry{header: normalizedHeader(header), content: content})
+	}
+
+	slices.SortStableFunc(entries, func(a, b repackEntry) int {
+		return cmp.Compare(a.header.Name, b.header.Name)
+	})
+
+	return entries, nil
+}
+
+// normalizedHeader returns the header of an entry without the metadata that changes between identical files.
+func normalizedHeader(h *tar.Header) *tar.Header {
+	typeflag := h.Typeflag
+	//nolint:staticcheck // TypeRegA is deprecated, but old archives still use it for regular files.
+	if typeflag == tar.TypeRegA {
+		typeflag = tar.TypeReg
+	}
+
+	return &tar.Header{
+		Typeflag: typeflag,
+		Name:     h.Name,
+		Linkname: h.Linkname,
+		Size:     h.Size,
+		Mode:     h.Mode & 0o777,
+		ModTime:  normalizedModTime,
+		Format:   tar.FormatPAX,
+	}
+}
+
+// writeRepackEntries writes the entries as a gzipped tar archive with a fixed gzip header.
+func writeRepackEntries(w io.Writer, entries []repackEntry) error {
+	gzw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return fmt.Errorf("unable to create gzip: %w", err)
+	}
+
+	// The zero header has no name, comment nor modification time, only the OS is set by default.
+	gzw.OS = 255
+
+	tw := tar.NewWriter(gzw)
+
+	for _, e := range entries {
+		err = tw.WriteHeader(e.header)
+		if err != nil {
+			return fmt.Errorf("unable to write tar header: %w", err)
+		}
+
+		_, err = tw.Write(e.content)
+		if err != nil {
+			return fmt.Errorf("unable to write tar content: %w", err)
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return fmt.Errorf("unable to write tar: %w", err)
+	}
+
+	err = gzw.Close()
+	if err != nil {
+		return fmt.Errorf("unable to write gzip: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v4/pkg/chart/v2/loader"
+)
+
+// writeTestChartArchive writes a chart archive with the entries in the given order, modification time and gzip name.
+func writeTestChartArchive(t *testing.T, p string, names []string, files map[string]string, mtime time.Time, gzName string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	gzw := gzip.NewWriter(&buf)
+	gzw.Name = gzName
+	gzw.ModTime = mtime
+
+	tw := tar.NewWriter(gzw)
+
+	for _, name := range names {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(len(files[name])),
+			ModTime:  mtime,
+			Uid:      1000,
+			Uname:    "builder",
+			Typeflag: tar.TypeReg,
+		}))
+
+		_, err := tw.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	require.NoError(t, os.WriteFile(p, buf.Bytes(), 0o600))
+}
+
+func TestRepackChart(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"mychart/Chart.yaml":            "apiVersion: v2\nname: mychart\nversion: 1.0.0\n",
+		"mychart/values.yaml":           "replicas: 1\n",
+		"mychart/templates/deploy.yaml": "kind: Deployment\n",
+	}
+
+	first := filepath.Join(dir, "first.tgz")
+	writeTestChartArchive(t, first, []string{"mychart/Chart.yaml", "mychart/values.yaml", "mychart/templates/deploy.yaml"},
+		files, time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC), "first.tar")
+
+	second := filepath.Join(dir, "second.tgz")
+	writeTestChartArchive(t, second, []string{"mychart/templates/deploy.yaml", "mychart/values.yaml", "mychart/Chart.yaml"},
+		files, time.Date(2026, time.June, 2, 8, 30, 0, 0, time.UTC), "")
+
+	firstDigest, err := fileDigest(first)
+	require.NoError(t, err)
+
+	secondDigest, err := fileDigest(second)
+	require.NoError(t, err)
+	require.NotEqual(t, firstDigest, secondDigest)
+
+	firstRepacked := filepath.Join(dir, "first-repacked.tgz")
+	require.NoError(t, repackChart(first, firstRepacked, DefaultExtractLimits))
+
+	secondRepacked := filepath.Join(dir, "second-repacked.tgz")
+	require.NoError(t, repackChart(second, secondRepacked, DefaultExtractLimits))
+
+	firstBytes, err := os.ReadFile(firstRepacked)
+	require.NoError(t, err)
+
+	secondBytes, err := os.ReadFile(secondRepacked)
+	require.NoError(t, err)
+	require.Equal(t, firstBytes, secondBytes, "identical content must be repacked to identical bytes")
+
+	ch, err := loader.LoadFile(firstRepacked)
+	require.NoError(t, err)
+	require.Equal(t, "mychart", ch.Name())
+	require.Len(t, ch.Templates, 1)
+
+	// The repacked archive is stable, repacking it again doesn't change it.
+	again := filepath.Join(dir, "again.tgz")
+	require.NoError(t, repackChart(firstRepacked, again, DefaultExtractLimits))
+
+	againBytes, err := os.ReadFile(again)
+	require.NoError(t, err)
+	require.Equal(t, firstBytes, againBytes)
+}
+
+func TestRepackChart_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	chart := filepath.Join(dir, "mychart.tgz")
+	writeTestChartArchive(t, chart, []string{"mychart/Chart.yaml"},
+		map[string]string{"mychart/Chart.yaml": "apiVersion: v2\nname: mychart\nversion: 1.0.0\n"}, time.Now(), "")
+
+	notGzip := filepath.Join(dir, "plain.tgz")
+	require.NoError(t, os.WriteFile(notGzip, []byte("not a gzip archive"), 0o600))
+
+	tests := []struct {
+		name   string
+		src    string
+		errMsg string
+		limits ExtractLimits
+	}{
+		{
+			name:   "missing archive",
+			src:    filepath.Join(dir, "missing.tgz"),
+			errMsg: "cannot open chart in repository cache",
+		},
+		{
+			name:   "not a gzip archive",
+			src:    notGzip,
+			errMsg: "unable to read gzip",
+		},
+		{
+			name:   "limits",
+			src:    chart,
+			limits: ExtractLimits{FileSize: 10},
+			errMsg: "chart archive file is too large: mychart/Chart.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repackChart(tt.src, filepath.Join(t.TempDir(), "out.tgz"), tt.limits)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
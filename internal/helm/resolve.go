@@ -0,0 +1,148 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"helm.sh/helm/v4/pkg/chart/v2/loader"
+	"helm.sh/helm/v4/pkg/getter"
+	"helm.sh/helm/v4/pkg/registry"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+var errUnknownRepositoryAlias = errors.New("unknown repository alias")
+
+// ResolveRepository turns a repository reference into its URL.
+// References starting with `@` are aliases of the repositories added with `helm repo add`,
+// anything else is returned as-is.
+func ResolveRepository(s *Settings, ref string) (string, error) {
+	alias, ok := strings.CutPrefix(ref, "@")
+	if !ok {
+		return ref, nil
+	}
+
+	rf, err := repo.LoadFile(s.RepositoryConfig)
+	if err != nil {
+		return "", fmt.Errorf("unable to read repositories file: %w", err)
+	}
+
+	e := rf.Get(alias)
+	if e == nil {
+		return "", fmt.Errorf("%w: %s", errUnknownRepositoryAlias, alias)
+	}
+
+	return e.URL, nil
+}
+
+// ResolveChartVersion checks that the chart exists in its repository and returns the version matching
+// its version or constraint, or the latest version when it is empty.
+// Helm repositories are looked up in a freshly downloaded index, OCI registries by their tags,
+// archive URLs, local and git repositories by the chart's own version.
+func ResolveChartVersion(s *Settings, vc *config.VendorChart) (string, error) {
+	if _, ok := vc.GitURL(); ok {
+		tmpDir, err := os.MkdirTemp("", "helm-vendor-package-")
+		if err != nil {
+			return "", fmt.Errorf("unable to create packaging directory: %w", err)
+		}
+
+		defer func() {
+			_ = os.RemoveAll(tmpDir)
+		}()
+
+		ch, _, _, err := loadGitChart(vc, tmpDir)
+		if err != nil {
+			return "", err
+		}
+
+		return matchVersion(ch.Metadata.Version, vc.Version)
+	}
+
+	if lp, ok := vc.LocalPath(); ok {
+		ch, _, err := loadLocalChart(lp, vc.Name)
+		if err != nil {
+			return "", err
+		}
+
+		return matchVersion(ch.Metadata.Version, vc.Version)
+	}
+
+	if vc.IsArchive() {
+		g, err := getter.NewHTTPGetter(getter.WithURL(vc.Repository), getter.WithInsecureSkipVerifyTLS(vc.Insecure))
+		if err != nil {
+			return "", fmt.Errorf("cannot create http getter: %w", err)
+		}
+
+		buf, err := g.Get(vc.Repository)
+		if err != nil {
+			return "", fmt.Errorf("unable to download chart archive: %w", err)
+		}
+
+		ch, err := loader.LoadArchive(buf)
+		if err != nil {
+			return "", fmt.Errorf("unable to load chart archive: %w", err)
+		}
+
+		err = validateChart(vc, ch, vc.Repository)
+		if err != nil {
+			return "", err
+		}
+
+		return ch.Metadata.Version, nil
+	}
+
+	getters := newGetters(s)
+
+	if registry.IsOCI(vc.Repository) {
+		rc, err := registry.NewClient(registry.ClientOptCredentialsFile(s.RegistryConfig))
+		if err != nil {
+			return "", fmt.Errorf("cannot create new OCI registry client: %w", err)
+		}
+
+		tags, err := rc.Tags(strings.TrimPrefix(vc.Repository, "oci://") + "/" + vc.Name)
+		if err != nil {
+			return "", fmt.Errorf("unable to list chart versions: %w", err)
+		}
+
+		v, err := registry.GetTagMatchingVersionOrConstraint(tags, vc.Version)
+		if err != nil {
+			return "", fmt.Errorf("unable to find chart version: %w", err)
+		}
+
+		return v, nil
+	}
+
+	cr, err := repo.NewChartRepository(&repo.Entry{Name: vc.Name, URL: vc.Repository, InsecureSkipTLSverify: vc.Insecure}, getters)
+	if err != nil {
+		return "", fmt.Errorf("invalid repository: %w", err)
+	}
+
+	// Keep the user's repository cache free of indexes that are not part of their repositories file.
+	cr.CachePath, err = os.MkdirTemp("", "helm-vendor-index-")
+	if err != nil {
+		return "", fmt.Errorf("unable to create index cache: %w", err)
+	}
+
+	defer func() {
+		_ = os.RemoveAll(cr.CachePath)
+	}()
+
+	idxPath, err := cr.DownloadIndexFile()
+	if err != nil {
+		return "", fmt.Errorf("unable to download repository index: %w", err)
+	}
+
+	idx, err := repo.LoadIndexFile(idxPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to load repository index: %w", err)
+	}
+
+	cv, err := idx.Get(vc.Name, vc.Version)
+	if err != nil {
+		return "", fmt.Errorf("unable to find chart in repository: %w", err)
+	}
+
+	return cv.Version, nil
+}
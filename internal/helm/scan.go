@@ -0,0 +1,151 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"helm.sh/helm/v4/pkg/chart/v2/loader"
+	"helm.sh/helm/v4/pkg/helmpath"
+	repo "helm.sh/helm/v4/pkg/repo/v1"
+)
+
+// ScanCharts walks the directory tree under root looking for already vendored charts,
+// either chart archives (`*.tgz`) or extracted chart directories (containing a `Chart.yaml`).
+// Destinations are relative to the working directory. Extracted charts are not searched for subcharts.
+// The repository of every chart is guessed from the locally cached indexes of the configured Helm repositories,
+// it is left empty when no repository serves the chart.
+//
+// Returns the found charts sorted by destination or an error if any.
+func ScanCharts(s *Settings, root string) ([]config.VendorChart, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine working directory: %w", err)
+	}
+
+	indexes := loadCachedIndexes(s)
+
+	var vcs []config.VendorChart
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if p != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			if _, sErr := os.Stat(filepath.Join(p, "Chart.yaml")); sErr != nil {
+				return nil //nolint:nilerr // Directories without a Chart.yaml are simply not charts.
+			}
+		} else if !strings.HasSuffix(d.Name(), ".tgz") {
+			return nil
+		}
+
+		vc, sErr := scanChart(wd, p, d.IsDir())
+		if sErr != nil {
+			slog.Warn("skipping invalid chart", "path", p, "error", sErr)
+			return nil
+		}
+
+		vc.Repository = guessRepository(indexes, vc.Name, vc.Version)
+		vcs = append(vcs, vc)
+
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan %s: %w", root, err)
+	}
+
+	slices.SortStableFunc(vcs, func(a, b config.VendorChart) int {
+		return strings.Compare(a.Destination, b.Destination)
+	})
+
+	return vcs, nil
+}
+
+// scanChart loads the chart archive or directory at p and describes it as a VendorChart.
+func scanChart(wd, p string, extracted bool) (config.VendorChart, error) {
+	ch, err := loader.Load(p)
+	if err != nil {
+		return config.VendorChart{}, fmt.Errorf("unable to load chart: %w", err)
+	}
+
+	dir := p
+	if !extracted {
+		dir = filepath.Dir(p)
+	}
+
+	if rel, rErr := filepath.Rel(wd, dir); rErr == nil {
+		dir = rel
+	}
+
+	vc := config.VendorChart{
+		Name:        ch.Metadata.Name,
+		Version:     ch.Metadata.Version,
+		Destination: filepath.ToSlash(dir),
+		Extract:     extracted,
+	}
+
+	if !extracted && filepath.Base(p) != vc.Name+"-"+vc.Version+".tgz" {
+		vc.Filename = filepath.Base(p)
+	}
+
+	return vc, nil
+}
+
+// cachedIndex is a repository index from the local Helm repository cache.
+type cachedIndex struct {
+	index *repo.IndexFile
+	url   string
+}
+
+// loadCachedIndexes reads the cached index of every repository in the Helm repositories file.
+// Missing or broken files are skipped, guessing the repository is best effort.
+func loadCachedIndexes(s *Settings) []cachedIndex {
+	rf, err := repo.LoadFile(s.RepositoryConfig)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Debug("unable to read repositories file", "path", s.RepositoryConfig, "error", err)
+		}
+
+		return nil
+	}
+
+	indexes := make([]cachedIndex, 0, len(rf.Repositories))
+
+	for _, e := range rf.Repositories {
+		idx, err := repo.LoadIndexFile(filepath.Join(s.RepositoryCache, helmpath.CacheIndexFile(e.Name)))
+		if err != nil {
+			slog.Debug("unable to read cached repository index", "repo", e.Name, "error", err)
+			continue
+		}
+
+		indexes = append(indexes, cachedIndex{index: idx, url: e.URL})
+	}
+
+	return indexes
+}
+
+// guessRepository returns the URL of the first repository serving the given chart version, or an empty string.
+func guessRepository(indexes []cachedIndex, name, version string) string {
+	for _, ci := range indexes {
+		if ci.index.Has(name, version) {
+			return ci.url
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,91 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shikachuu/helm-vendor-plugin/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanCharts(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "repo")
+
+	chartYAML := func(name, version string) string {
+		return "apiVersion: v2\nname: " + name + "\nversion: " + version + "\n"
+	}
+
+	files := map[string]string{
+		"ingress/Chart.yaml":                   chartYAML("ingress-nginx", "4.11.0"),
+		"ingress/charts/sub/Chart.yaml":        chartYAML("sub", "0.1.0"),
+		"ingress/templates/deploy.yaml":        "kind: Deployment",
+		".git/Chart.yaml":                      chartYAML("hidden", "1.0.0"),
+		"monitoring/not-a-chart/values.yaml":   "replicas: 1",
+		"monitoring/archives/broken-1.0.0.tgz": "not a chart",
+	}
+
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o750))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+	}
+
+	archives := map[string]string{
+		"monitoring/archives/prometheus-27.45.0.tgz": "prometheus",
+		"monitoring/archives/grafana.tgz":            "grafana",
+	}
+
+	for p, name := range archives {
+		version := "27.45.0"
+		if name == "grafana" {
+			version = "8.0.0"
+		}
+
+		tgz := createTestTarGz(t, name, map[string]string{"Chart.yaml": chartYAML(name, version)})
+		require.NoError(t, os.WriteFile(filepath.Join(root, p), tgz, 0o600))
+	}
+
+	cacheDir := filepath.Join(tmpDir, "cache")
+	require.NoError(t, os.MkdirAll(cacheDir, 0o750))
+
+	repoConfig := filepath.Join(tmpDir, "repositories.yaml")
+	require.NoError(t, os.WriteFile(repoConfig, []byte(`apiVersion: v1
+repositories:
+  - name: prometheus-community
+    url: https://prometheus-community.github.io/helm-charts
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "prometheus-community-index.yaml"), []byte(`apiVersion: v1
+entries:
+  prometheus:
+    - name: prometheus
+      version: 27.45.0
+      urls:
+        - prometheus-27.45.0.tgz
+`), 0o600))
+
+	vcs, err := ScanCharts(&Settings{RepositoryConfig: repoConfig, RepositoryCache: cacheDir}, root)
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	rel := func(p string) string {
+		r, rErr := filepath.Rel(wd, filepath.Join(root, p))
+		require.NoError(t, rErr)
+
+		return filepath.ToSlash(r)
+	}
+
+	require.Equal(t, []config.VendorChart{
+		{Name: "ingress-nginx", Version: "4.11.0", Destination: rel("ingress"), Extract: true},
+		{Name: "grafana", Version: "8.0.0", Destination: rel("monitoring/archives"), Filename: "grafana.tgz"},
+		{
+			Name:        "prometheus",
+			Version:     "27.45.0",
+			Repository:  "https://prometheus-community.github.io/helm-charts",
+			Destination: rel("monitoring/archives"),
+		},
+	}, vcs)
+}
@@ -10,6 +10,7 @@ type Settings struct {
 	RepositoryConfig string
 	RepositoryCache  string
 	ContentCache     string
+	PluginsDirectory string
 	Debug            bool
 }
 
@@ -21,6 +22,7 @@ func NewSettings() *Settings {
 		RepositoryConfig: os.Getenv("HELM_REPOSITORY_CONFIG"),
 		RepositoryCache:  os.Getenv("HELM_REPOSITORY_CACHE"),
 		ContentCache:     os.Getenv("HELM_CONTENT_CACHE"),
+		PluginsDirectory: os.Getenv("HELM_PLUGINS"),
 		Debug:            false,
 	}
 
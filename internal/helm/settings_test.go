@@ -13,6 +13,7 @@ func TestNewSettings(t *testing.T) {
 		"HELM_REPOSITORY_CONFIG": "",
 		"HELM_REPOSITORY_CACHE":  "",
 		"HELM_CONTENT_CACHE":     "",
+		"HELM_PLUGINS":           "",
 	}
 	for k, v := range envs {
 		t.Setenv(k, v)
@@ -24,5 +25,6 @@ func TestNewSettings(t *testing.T) {
 	assert.Equal(t, s.RepositoryConfig, envs["HELM_REPOSITORY_CONFIG"])
 	assert.Equal(t, s.RepositoryCache, envs["HELM_REPOSITORY_CACHE"])
 	assert.Equal(t, s.ContentCache, envs["HELM_CONTENT_CACHE"])
+	assert.Equal(t, s.PluginsDirectory, envs["HELM_PLUGINS"])
 	assert.Equal(t, s.Debug, envs["HELM_DEBUG"] == "1")
 }